@@ -0,0 +1,5 @@
+package v1
+
+// Hub marks v1 as the version the other versions of LRP are converted
+// through
+func (*LRP) Hub() {}
@@ -18,6 +18,9 @@ limitations under the License.
 package v1
 
 import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -41,9 +44,19 @@ type LRPSpec struct {
 	Sidecars        []Sidecar         `json:"sidecars,omitempty"`
 	PrivateRegistry *PrivateRegistry  `json:"privateRegistry,omitempty"`
 	Env             map[string]string `json:"env,omitempty"`
-	Health          Healthcheck       `json:"health"`
-	Ports           []int32           `json:"ports,omitempty"`
+	// SecretEnv sets environment variables from keys of Secrets in the
+	// namespace of the LRP
+	SecretEnv []SecretEnvVar `json:"secretEnv,omitempty"`
+	// EnvInSecret moves Env into a Secret owned by the LRP, which the
+	// instances reference instead of carrying the values in their pod
+	// template. Changing the Secret restarts the instances.
+	EnvInSecret bool        `json:"envInSecret,omitempty"`
+	Health      Healthcheck `json:"health"`
+	Ports       []int32     `json:"ports,omitempty"`
+	// Routes expose ports of the LRP outside the cluster, through an Ingress
+	Routes []Route `json:"routes,omitempty"`
 	// +kubebuilder:default:=1
+	// +kubebuilder:validation:Minimum:=0
 	Instances int   `json:"instances"`
 	MemoryMB  int64 `json:"memoryMB"`
 	// +kubebuilder:validation:Minimum:=1
@@ -54,10 +67,105 @@ type LRPSpec struct {
 	VolumeMounts           []VolumeMount     `json:"volumeMounts,omitempty"`
 	LastUpdated            string            `json:"lastUpdated"`
 	UserDefinedAnnotations map[string]string `json:"userDefinedAnnotations,omitempty"`
+	// InstanceRestarts asks for single instances to be restarted. Each
+	// request is run once, even if it stays in the spec.
+	// +listType=map
+	// +listMapKey=id
+	InstanceRestarts []InstanceRestartRequest `json:"instanceRestarts,omitempty"`
+	// Autoscaling lets a HorizontalPodAutoscaler set the number of
+	// instances. When it is set, instances should not be changed otherwise.
+	Autoscaling *Autoscaling `json:"autoscaling,omitempty"`
+}
+
+// SecretEnvVar is an environment variable whose value is a key of a Secret
+type SecretEnvVar struct {
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// +kubebuilder:validation:Required
+	SecretName string `json:"secretName"`
+	// +kubebuilder:validation:Required
+	Key string `json:"key"`
+}
+
+type Autoscaling struct {
+	// +kubebuilder:validation:Minimum:=1
+	MinInstances *int32 `json:"minInstances,omitempty"`
+	// +kubebuilder:validation:Minimum:=1
+	MaxInstances int32 `json:"maxInstances"`
+	// +kubebuilder:validation:Minimum:=1
+	TargetCPUUtilizationPercentage *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+	// +kubebuilder:validation:Minimum:=1
+	TargetMemoryUtilizationPercentage *int32 `json:"targetMemoryUtilizationPercentage,omitempty"`
+}
+
+type InstanceRestartRequest struct {
+	// ID identifies the request and must be unique, e.g. a nonce
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength:=1
+	ID string `json:"id"`
+	// +kubebuilder:validation:Minimum:=0
+	Index int `json:"index"`
+}
+
+const (
+	LRPConditionReady                 = "Ready"
+	LRPConditionProgressing           = "Progressing"
+	LRPConditionDegraded              = "Degraded"
+	LRPConditionImagePullFailed       = "ImagePullFailed"
+	LRPConditionInsufficientResources = "InsufficientResources"
+	LRPConditionRolledBack            = "RolledBack"
+)
+
+// InstanceStatus describes a single instance (pod) of an LRP
+type InstanceStatus struct {
+	Index int `json:"index"`
+	// +kubebuilder:validation:Enum=RUNNING;CLAIMED;CRASHED;UNCLAIMED;UNKNOWN
+	State           string       `json:"state"`
+	Since           *metav1.Time `json:"since,omitempty"`
+	PlacementError  string       `json:"placementError,omitempty"`
+	RestartCount    int32        `json:"restartCount"`
+	LastCrashReason string       `json:"lastCrashReason,omitempty"`
+}
+
+type InstanceRestartStatus struct {
+	ID          string      `json:"id"`
+	Index       int         `json:"index"`
+	HandledTime metav1.Time `json:"handledTime"`
+	Error       string      `json:"error,omitempty"`
 }
 
 type LRPStatus struct {
 	Replicas int32 `json:"replicas"`
+	// Selector is the label selector of the LRP instances, used by the
+	// scale subresource
+	Selector           string `json:"selector,omitempty"`
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+	RunningInstances   int32  `json:"runningInstances"`
+	StartingInstances  int32  `json:"startingInstances"`
+	CrashedInstances   int32  `json:"crashedInstances"`
+	// +listType=map
+	// +listMapKey=index
+	Instances []InstanceStatus `json:"instances,omitempty"`
+	// ActiveVersion is the version serving the app. While a new version is
+	// coming up it is still the previous one.
+	ActiveVersion string `json:"activeVersion,omitempty"`
+	// PreviousVersion is the version that was active before ActiveVersion
+	PreviousVersion string `json:"previousVersion,omitempty"`
+	// RetiringVersions are the versions whose StatefulSets are being stopped
+	RetiringVersions []string `json:"retiringVersions,omitempty"`
+	// FailedVersion is a version that did not become ready in time and was
+	// rolled back. It is not desired again until the spec version changes.
+	FailedVersion string `json:"failedVersion,omitempty"`
+	// VersionTransitionStartTime is when the current version transition began
+	VersionTransitionStartTime *metav1.Time `json:"versionTransitionStartTime,omitempty"`
+	// HandledInstanceRestarts are the instance restart requests in the spec
+	// that have already been run
+	// +listType=map
+	// +listMapKey=id
+	HandledInstanceRestarts []InstanceRestartStatus `json:"handledInstanceRestarts,omitempty"`
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 type Route struct {
@@ -65,6 +173,11 @@ type Route struct {
 	Port     int32  `json:"port"`
 }
 
+const (
+	SidecarStartBeforeApp = "BeforeApp"
+	SidecarStartAfterApp  = "AfterApp"
+)
+
 type Sidecar struct {
 	// +kubebuilder:validation:Required
 	Name string `json:"name"`
@@ -72,28 +185,131 @@ type Sidecar struct {
 	Command  []string          `json:"command"`
 	MemoryMB int64             `json:"memoryMB"`
 	Env      map[string]string `json:"env,omitempty"`
+	// Image defaults to the app image
+	Image string  `json:"image,omitempty"`
+	Ports []int32 `json:"ports,omitempty"`
+	// CPUWeight defaults to the app CPU weight
+	// +kubebuilder:validation:Format:=uint8
+	CPUWeight *uint8 `json:"cpuWeight,omitempty"`
+	// DiskMB defaults to the app disk limit
+	// +kubebuilder:validation:Minimum:=1
+	DiskMB *int64       `json:"diskMB,omitempty"`
+	Health *Healthcheck `json:"health,omitempty"`
+	// Start says whether the sidecar container is placed before or after the
+	// app container. The kubelet starts containers in that order, but does
+	// not wait for one to be running or ready before starting the next, so
+	// the order is best-effort. Apps that depend on a sidecar, such as a
+	// proxy, still have to retry until it is up.
+	// +kubebuilder:validation:Enum=BeforeApp;AfterApp
+	// +kubebuilder:default:=AfterApp
+	Start string `json:"start,omitempty"`
 }
 
+// PrivateRegistry holds the credentials for pulling the image, either
+// inline or from a Secret in the same namespace. The Secret may be of type
+// kubernetes.io/dockerconfigjson, in which case it is used as the pull
+// secret, or kubernetes.io/basic-auth.
 type PrivateRegistry struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username   string `json:"username,omitempty"`
+	Password   string `json:"password,omitempty"`
+	SecretName string `json:"secretName,omitempty"`
 }
 
+// VolumeMount mounts a volume into the app container. Exactly one of
+// ClaimName, ConfigMap, Secret and EmptyDir must be set.
 type VolumeMount struct {
+	// +kubebuilder:validation:Required
 	MountPath string `json:"mountPath"`
-	ClaimName string `json:"claimName"`
+	ReadOnly  bool   `json:"readOnly,omitempty"`
+	SubPath   string `json:"subPath,omitempty"`
+	// ClaimName mounts an existing PersistentVolumeClaim
+	ClaimName string                 `json:"claimName,omitempty"`
+	ConfigMap *ConfigMapVolumeSource `json:"configMap,omitempty"`
+	Secret    *SecretVolumeSource    `json:"secret,omitempty"`
+	EmptyDir  *EmptyDirVolumeSource  `json:"emptyDir,omitempty"`
+}
+
+// VolumeName is the name of the pod volume behind the mount at index i of
+// the volume mounts. Claim volumes are named after the claim, as the vendored
+// converter does, so that existing StatefulSets are not rolled, and the
+// other volumes after their source and index.
+func (m VolumeMount) VolumeName(i int) string {
+	switch {
+	case m.ClaimName != "":
+		return m.ClaimName
+	case m.ConfigMap != nil:
+		return fmt.Sprintf("configmap-%d", i)
+	case m.Secret != nil:
+		return fmt.Sprintf("secret-%d", i)
+	case m.EmptyDir != nil:
+		return fmt.Sprintf("emptydir-%d", i)
+	default:
+		return ""
+	}
+}
+
+type ConfigMapVolumeSource struct {
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+}
+
+type SecretVolumeSource struct {
+	// +kubebuilder:validation:Required
+	SecretName string `json:"secretName"`
+}
+
+type EmptyDirVolumeSource struct {
+	// +kubebuilder:validation:Enum="";Memory
+	Medium    string             `json:"medium,omitempty"`
+	SizeLimit *resource.Quantity `json:"sizeLimit,omitempty"`
 }
 
+const (
+	HealthcheckTypeHTTP    = "http"
+	HealthcheckTypePort    = "port"
+	HealthcheckTypeProcess = "process"
+)
+
 type Healthcheck struct {
+	// Type is the kind of check. A process check only relies on the app
+	// process staying alive and has no probes.
+	// +kubebuilder:validation:Enum=http;port;process;""
 	Type     string `json:"type"`
 	Port     int32  `json:"port"`
 	Endpoint string `json:"endpoint"`
+	// TimeoutMs is the start timeout of the app. When set, a startup probe
+	// gives the app this long to pass its first check before the liveness
+	// probe takes over.
 	// +kubebuilder:validation:Format:=uint8
 	TimeoutMs uint `json:"timeoutMs"`
+	// +kubebuilder:validation:Minimum:=1
+	InvocationTimeoutSeconds *int32 `json:"invocationTimeoutSeconds,omitempty"`
+	// +kubebuilder:validation:Minimum:=1
+	PeriodSeconds *int32 `json:"periodSeconds,omitempty"`
+	// +kubebuilder:validation:Minimum:=1
+	FailureThreshold *int32 `json:"failureThreshold,omitempty"`
+	// Readiness is the check deciding whether an instance gets traffic.
+	// When not set, the liveness check is used.
+	Readiness *ReadinessCheck `json:"readiness,omitempty"`
+}
+
+type ReadinessCheck struct {
+	// +kubebuilder:validation:Enum=http;port
+	Type     string `json:"type"`
+	Port     int32  `json:"port"`
+	Endpoint string `json:"endpoint,omitempty"`
+	// +kubebuilder:validation:Minimum:=1
+	InvocationTimeoutSeconds *int32 `json:"invocationTimeoutSeconds,omitempty"`
+	// +kubebuilder:validation:Minimum:=1
+	PeriodSeconds *int32 `json:"periodSeconds,omitempty"`
+	// +kubebuilder:validation:Minimum:=1
+	FailureThreshold *int32 `json:"failureThreshold,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:subresource:scale:specpath=.spec.instances,statuspath=.status.replicas,selectorpath=.status.selector
 
 // LRP is the Schema for the lrps API
 type LRP struct {
@@ -17,8 +17,20 @@ limitations under the License.
 package v1
 
 import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
@@ -26,7 +38,17 @@ import (
 // log is for logging in this package.
 var lrplog = logf.Log.WithName("lrp-resource")
 
+// appContainerName is the name the vendored converter gives the app
+// container, see stset.ApplicationContainerName
+const appContainerName = "opi"
+
+// webhookReader reads the objects validation depends on straight from the
+// API server, so that the webhooks do not start informers for them
+var webhookReader client.Reader
+
 func (r *LRP) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	webhookReader = mgr.GetAPIReader()
+
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
@@ -34,8 +56,51 @@ func (r *LRP) SetupWebhookWithManager(mgr ctrl.Manager) error {
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 
-// TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
-//+kubebuilder:webhook:path=/validate-eirini-cloudfoundry-org-v1-lrp,mutating=false,failurePolicy=fail,sideEffects=None,groups=eirini.cloudfoundry.org,resources=lrps,verbs=update,versions=v1,name=vlrp.kb.io,admissionReviewVersions={v1,v1beta1}
+// applyDefaults is called by the LRPDefaulter
+func (r *LRP) applyDefaults(defaults Defaults) {
+	lrplog.Info("default", "name", r.Name)
+
+	if r.Spec.ProcessType == "" {
+		r.Spec.ProcessType = defaults.ProcessType
+	}
+
+	if r.Spec.DiskMB == 0 {
+		r.Spec.DiskMB = defaults.DiskMB
+	}
+
+	if r.Spec.CPUWeight == 0 {
+		r.Spec.CPUWeight = defaults.cpuWeight(r.Spec.MemoryMB)
+	}
+
+	if r.Spec.LastUpdated == "" {
+		r.Spec.LastUpdated = strconv.FormatInt(time.Now().Unix(), 10)
+	}
+
+	r.defaultHealthcheck(defaults)
+}
+
+func (r *LRP) defaultHealthcheck(defaults Defaults) {
+	health := &r.Spec.Health
+
+	if health.Type == "" {
+		health.Type = HealthcheckTypeProcess
+		if len(r.Spec.Ports) > 0 {
+			health.Type = defaults.HealthcheckType
+		}
+	}
+
+	if checksPort(health.Type) && health.Port == 0 && len(r.Spec.Ports) > 0 {
+		health.Port = r.Spec.Ports[0]
+	}
+
+	if health.TimeoutMs == 0 {
+		health.TimeoutMs = defaults.HealthcheckTimeoutMs
+	}
+}
+
+//+kubebuilder:rbac:groups="",resources=configmaps;persistentvolumeclaims;secrets,verbs=get
+
+//+kubebuilder:webhook:path=/validate-eirini-cloudfoundry-org-v1-lrp,mutating=false,failurePolicy=fail,sideEffects=None,groups=eirini.cloudfoundry.org,resources=lrps,verbs=create;update,versions=v1,name=vlrp.kb.io,admissionReviewVersions={v1,v1beta1}
 
 var _ webhook.Validator = &LRP{}
 
@@ -43,16 +108,19 @@ var _ webhook.Validator = &LRP{}
 func (r *LRP) ValidateCreate() error {
 	lrplog.Info("validate create", "name", r.Name)
 
-	// TODO(user): fill in your validation logic upon object creation.
-	return nil
+	return r.validate(nil)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *LRP) ValidateUpdate(old runtime.Object) error {
 	lrplog.Info("validate update", "name", r.Name)
 
-	// TODO(user): fill in your validation logic upon object update.
-	return nil
+	oldLRP, ok := old.(*LRP)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected an LRP but got a %T", old))
+	}
+
+	return r.validate(oldLRP)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -62,3 +130,243 @@ func (r *LRP) ValidateDelete() error {
 	// TODO(user): fill in your validation logic upon object deletion.
 	return nil
 }
+
+// validate checks the whole spec on create. On update it lets through LRPs
+// that are being deleted and changes that leave the spec alone, such as the
+// controller adding or removing its finalizer, so that LRPs created before a
+// rule was added, or whose volumes have gone, can still be cleaned up. The
+// sources of volume mounts are only looked up when the mounts change.
+func (r *LRP) validate(old *LRP) error {
+	if old != nil && (r.DeletionTimestamp != nil || apiequality.Semantic.DeepEqual(r.Spec, old.Spec)) {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+
+	specPath := field.NewPath("spec")
+	checkVolumes := old == nil || !apiequality.Semantic.DeepEqual(r.Spec.VolumeMounts, old.Spec.VolumeMounts)
+
+	if old != nil && r.Spec.GUID != old.Spec.GUID {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("GUID"), "is immutable"))
+	}
+
+	if r.Spec.MemoryMB <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("memoryMB"), r.Spec.MemoryMB, "must be greater than 0"))
+	}
+
+	if r.Spec.DiskMB <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("diskMB"), r.Spec.DiskMB, "must be greater than 0"))
+	}
+
+	allErrs = append(allErrs, validatePorts(specPath.Child("ports"), r.Spec.Ports)...)
+	allErrs = append(allErrs, r.validateHealthcheck()...)
+	allErrs = append(allErrs, validateEnv(specPath.Child("env"), r.Spec.Env)...)
+	allErrs = append(allErrs, validateSecretEnv(specPath.Child("secretEnv"), r.Spec.SecretEnv)...)
+	allErrs = append(allErrs, r.validateSidecars()...)
+	allErrs = append(allErrs, validateVolumeMounts(r.Namespace, specPath.Child("volumeMounts"), r.Spec.VolumeMounts, checkVolumes)...)
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(GroupVersion.WithKind("LRP").GroupKind(), r.Name, allErrs)
+}
+
+// validateHealthcheck makes sure http and port checks target a port the app
+// listens on
+func (r *LRP) validateHealthcheck() field.ErrorList {
+	return validateHealthcheckPorts(field.NewPath("spec", "health"), r.Spec.Health, r.Spec.Ports, "spec.ports")
+}
+
+func validateHealthcheckPorts(healthPath *field.Path, health Healthcheck, ports []int32, portsPath string) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if checksPort(health.Type) && !containsPort(ports, health.Port) {
+		allErrs = append(allErrs, field.Invalid(healthPath.Child("port"), health.Port, "must be one of "+portsPath))
+	}
+
+	if readiness := health.Readiness; readiness != nil && checksPort(readiness.Type) && !containsPort(ports, readiness.Port) {
+		allErrs = append(allErrs, field.Invalid(healthPath.Child("readiness", "port"), readiness.Port, "must be one of "+portsPath))
+	}
+
+	return allErrs
+}
+
+func containsPort(ports []int32, port int32) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+
+	return false
+}
+
+func checksPort(checkType string) bool {
+	return checkType == HealthcheckTypeHTTP || checkType == HealthcheckTypePort
+}
+
+// validateSidecars makes sure sidecars can be told apart from each other and
+// from the app container, and that their health checks target their own ports
+func (r *LRP) validateSidecars() field.ErrorList {
+	var allErrs field.ErrorList
+
+	sidecarsPath := field.NewPath("spec", "sidecars")
+	names := map[string]bool{}
+
+	for i, sidecar := range r.Spec.Sidecars {
+		sidecarPath := sidecarsPath.Index(i)
+
+		switch {
+		case sidecar.Name == appContainerName:
+			allErrs = append(allErrs, field.Invalid(sidecarPath.Child("name"), sidecar.Name, "must not be the name of the app container"))
+		case names[sidecar.Name]:
+			allErrs = append(allErrs, field.Duplicate(sidecarPath.Child("name"), sidecar.Name))
+		}
+
+		names[sidecar.Name] = true
+
+		allErrs = append(allErrs, validatePorts(sidecarPath.Child("ports"), sidecar.Ports)...)
+		allErrs = append(allErrs, validateEnv(sidecarPath.Child("env"), sidecar.Env)...)
+
+		if sidecar.Health != nil {
+			portsPath := sidecarPath.Child("ports").String()
+			allErrs = append(allErrs, validateHealthcheckPorts(sidecarPath.Child("health"), *sidecar.Health, sidecar.Ports, portsPath)...)
+		}
+	}
+
+	return allErrs
+}
+
+func validatePorts(path *field.Path, ports []int32) field.ErrorList {
+	var allErrs field.ErrorList
+
+	seen := map[int32]bool{}
+
+	for i, port := range ports {
+		for _, msg := range validation.IsValidPortNum(int(port)) {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), port, msg))
+		}
+
+		if seen[port] {
+			allErrs = append(allErrs, field.Duplicate(path.Index(i), port))
+		}
+
+		seen[port] = true
+	}
+
+	return allErrs
+}
+
+func validateEnv(path *field.Path, env map[string]string) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for name := range env {
+		for _, msg := range validation.IsEnvVarName(name) {
+			allErrs = append(allErrs, field.Invalid(path.Key(name), name, msg))
+		}
+	}
+
+	return allErrs
+}
+
+func validateSecretEnv(path *field.Path, secretEnv []SecretEnvVar) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for i, env := range secretEnv {
+		for _, msg := range validation.IsEnvVarName(env.Name) {
+			allErrs = append(allErrs, field.Invalid(path.Index(i).Child("name"), env.Name, msg))
+		}
+	}
+
+	return allErrs
+}
+
+// validateVolumeMounts makes sure every mount has its own path and exactly
+// one source. With checkExistence it also makes sure the claims, config maps
+// and secrets it references exist.
+func validateVolumeMounts(namespace string, path *field.Path, mounts []VolumeMount, checkExistence bool) field.ErrorList {
+	var allErrs field.ErrorList
+
+	mountPaths := map[string]bool{}
+	generatedVolumes := map[string]int{}
+
+	for i, mount := range mounts {
+		if mount.ClaimName == "" {
+			generatedVolumes[mount.VolumeName(i)] = i
+		}
+	}
+
+	for i, mount := range mounts {
+		mountPath := path.Index(i)
+
+		cleanPath := filepath.Clean(mount.MountPath)
+		if mountPaths[cleanPath] {
+			allErrs = append(allErrs, field.Duplicate(mountPath.Child("mountPath"), mount.MountPath))
+		}
+
+		mountPaths[cleanPath] = true
+
+		sources := 0
+		for _, set := range []bool{mount.ClaimName != "", mount.ConfigMap != nil, mount.Secret != nil, mount.EmptyDir != nil} {
+			if set {
+				sources++
+			}
+		}
+
+		if sources != 1 {
+			allErrs = append(allErrs, field.Invalid(mountPath, mount.MountPath, "exactly one of claimName, configMap, secret and emptyDir must be set"))
+
+			continue
+		}
+
+		if j, ok := generatedVolumes[mount.ClaimName]; ok && mount.ClaimName != "" {
+			msg := fmt.Sprintf("must not be the name of the volume generated for %s", path.Index(j))
+			allErrs = append(allErrs, field.Invalid(mountPath.Child("claimName"), mount.ClaimName, msg))
+		}
+
+		if !checkExistence {
+			continue
+		}
+
+		var (
+			obj      client.Object
+			name     string
+			namePath *field.Path
+		)
+
+		switch {
+		case mount.ClaimName != "":
+			obj, name, namePath = &corev1.PersistentVolumeClaim{}, mount.ClaimName, mountPath.Child("claimName")
+		case mount.ConfigMap != nil:
+			obj, name, namePath = &corev1.ConfigMap{}, mount.ConfigMap.Name, mountPath.Child("configMap", "name")
+		case mount.Secret != nil:
+			obj, name, namePath = &corev1.Secret{}, mount.Secret.SecretName, mountPath.Child("secret", "secretName")
+		default:
+			continue
+		}
+
+		if err := objectExists(namespace, name, obj, namePath); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+
+	return allErrs
+}
+
+func objectExists(namespace, name string, obj client.Object, path *field.Path) *field.Error {
+	if webhookReader == nil {
+		return nil
+	}
+
+	err := webhookReader.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: name}, obj)
+
+	switch {
+	case err == nil:
+		return nil
+	case apierrors.IsNotFound(err):
+		return field.NotFound(path, name)
+	default:
+		return field.InternalError(path, err)
+	}
+}
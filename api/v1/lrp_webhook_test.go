@@ -0,0 +1,315 @@
+package v1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("LRP Webhook", func() {
+	var lrp *LRP
+
+	BeforeEach(func() {
+		lrp = &LRP{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "lrp-",
+				Namespace:    "default",
+			},
+			Spec: LRPSpec{
+				GUID:     "guid",
+				Version:  "version",
+				Image:    "eirini/dorini",
+				MemoryMB: 256,
+				DiskMB:   2,
+				Ports:    []int32{8080},
+				Health: Healthcheck{
+					Type: HealthcheckTypePort,
+					Port: 8080,
+				},
+			},
+		}
+	})
+
+	It("accepts a health check on one of the lrp ports", func() {
+		Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+	})
+
+	When("the health check port is not one of the lrp ports", func() {
+		BeforeEach(func() {
+			lrp.Spec.Health.Port = 9090
+		})
+
+		It("rejects the lrp", func() {
+			err := k8sClient.Create(ctx, lrp)
+			Expect(err).To(MatchError(ContainSubstring("spec.health.port")))
+		})
+	})
+
+	When("the readiness check port is not one of the lrp ports", func() {
+		BeforeEach(func() {
+			lrp.Spec.Health.Readiness = &ReadinessCheck{Type: HealthcheckTypeHTTP, Port: 9090, Endpoint: "/ready"}
+		})
+
+		It("rejects the lrp", func() {
+			err := k8sClient.Create(ctx, lrp)
+			Expect(err).To(MatchError(ContainSubstring("spec.health.readiness.port")))
+		})
+	})
+
+	When("the health check is a process check", func() {
+		BeforeEach(func() {
+			lrp.Spec.Ports = nil
+			lrp.Spec.Health = Healthcheck{Type: HealthcheckTypeProcess}
+		})
+
+		It("does not require a port", func() {
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+		})
+	})
+
+	When("a volume mount references a config map that does not exist", func() {
+		BeforeEach(func() {
+			lrp.Spec.VolumeMounts = []VolumeMount{
+				{MountPath: "/etc/app", ConfigMap: &ConfigMapVolumeSource{Name: "missing"}},
+			}
+		})
+
+		It("rejects the lrp", func() {
+			err := k8sClient.Create(ctx, lrp)
+			Expect(err).To(MatchError(ContainSubstring("spec.volumeMounts[0].configMap.name")))
+		})
+	})
+
+	When("a claim has the name of a volume generated for another mount", func() {
+		BeforeEach(func() {
+			lrp.Spec.VolumeMounts = []VolumeMount{
+				{MountPath: "/data", EmptyDir: &EmptyDirVolumeSource{}},
+				{MountPath: "/var/data", ClaimName: "emptydir-0"},
+			}
+		})
+
+		It("rejects the lrp", func() {
+			err := k8sClient.Create(ctx, lrp)
+			Expect(err).To(MatchError(ContainSubstring("spec.volumeMounts[1].claimName: Invalid value: \"emptydir-0\": must not be the name of the volume generated for spec.volumeMounts[0]")))
+		})
+	})
+
+	When("a volume mount references a config map that exists", func() {
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "default"},
+			})).To(Succeed())
+
+			lrp.Spec.VolumeMounts = []VolumeMount{
+				{MountPath: "/etc/app", ReadOnly: true, ConfigMap: &ConfigMapVolumeSource{Name: "app-config"}},
+			}
+		})
+
+		It("accepts the lrp", func() {
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+		})
+	})
+
+	When("a volume mount sets more than one source", func() {
+		BeforeEach(func() {
+			lrp.Spec.VolumeMounts = []VolumeMount{
+				{MountPath: "/data", ClaimName: "claim", EmptyDir: &EmptyDirVolumeSource{}},
+			}
+		})
+
+		It("rejects the lrp", func() {
+			err := k8sClient.Create(ctx, lrp)
+			Expect(err).To(MatchError(ContainSubstring("exactly one of claimName, configMap, secret and emptyDir")))
+		})
+	})
+
+	When("the memory is not positive", func() {
+		BeforeEach(func() {
+			lrp.Spec.MemoryMB = 0
+		})
+
+		It("rejects the lrp", func() {
+			err := k8sClient.Create(ctx, lrp)
+			Expect(err).To(MatchError(ContainSubstring("spec.memoryMB")))
+		})
+	})
+
+	When("a port is out of range", func() {
+		BeforeEach(func() {
+			lrp.Spec.Ports = []int32{8080, 70000}
+		})
+
+		It("rejects the lrp", func() {
+			err := k8sClient.Create(ctx, lrp)
+			Expect(err).To(MatchError(ContainSubstring("spec.ports[1]")))
+		})
+	})
+
+	When("a port is listed twice", func() {
+		BeforeEach(func() {
+			lrp.Spec.Ports = []int32{8080, 8080}
+		})
+
+		It("rejects the lrp", func() {
+			err := k8sClient.Create(ctx, lrp)
+			Expect(err).To(MatchError(ContainSubstring("spec.ports[1]: Duplicate value")))
+		})
+	})
+
+	When("a sidecar has the name of the app container", func() {
+		BeforeEach(func() {
+			lrp.Spec.Sidecars = []Sidecar{{Name: "opi", Command: []string{"sleep"}}}
+		})
+
+		It("rejects the lrp", func() {
+			err := k8sClient.Create(ctx, lrp)
+			Expect(err).To(MatchError(ContainSubstring("spec.sidecars[0].name")))
+		})
+	})
+
+	When("two sidecars have the same name", func() {
+		BeforeEach(func() {
+			lrp.Spec.Sidecars = []Sidecar{
+				{Name: "proxy", Command: []string{"envoy"}},
+				{Name: "proxy", Command: []string{"envoy"}},
+			}
+		})
+
+		It("rejects the lrp", func() {
+			err := k8sClient.Create(ctx, lrp)
+			Expect(err).To(MatchError(ContainSubstring("spec.sidecars[1].name: Duplicate value")))
+		})
+	})
+
+	When("a sidecar health check targets a port the sidecar does not expose", func() {
+		BeforeEach(func() {
+			lrp.Spec.Sidecars = []Sidecar{{
+				Name:    "proxy",
+				Command: []string{"envoy"},
+				Ports:   []int32{9000},
+				Health:  &Healthcheck{Type: HealthcheckTypePort, Port: 8080},
+			}}
+		})
+
+		It("rejects the lrp", func() {
+			err := k8sClient.Create(ctx, lrp)
+			Expect(err).To(MatchError(ContainSubstring("spec.sidecars[0].health.port")))
+		})
+	})
+
+	When("an env var name is not valid", func() {
+		BeforeEach(func() {
+			lrp.Spec.Env = map[string]string{"1=BAD": "value"}
+		})
+
+		It("rejects the lrp", func() {
+			err := k8sClient.Create(ctx, lrp)
+			Expect(err).To(MatchError(ContainSubstring("spec.env[1=BAD]")))
+		})
+	})
+
+	When("two volumes are mounted at the same path", func() {
+		BeforeEach(func() {
+			lrp.Spec.VolumeMounts = []VolumeMount{
+				{MountPath: "/data", EmptyDir: &EmptyDirVolumeSource{}},
+				{MountPath: "/data/", EmptyDir: &EmptyDirVolumeSource{}},
+			}
+		})
+
+		It("rejects the lrp", func() {
+			err := k8sClient.Create(ctx, lrp)
+			Expect(err).To(MatchError(ContainSubstring("spec.volumeMounts[1].mountPath: Duplicate value")))
+		})
+	})
+
+	When("the guid is changed", func() {
+		It("rejects the update", func() {
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+
+			lrp.Spec.GUID = "another-guid"
+			err := k8sClient.Update(ctx, lrp)
+			Expect(err).To(MatchError(ContainSubstring("spec.GUID")))
+		})
+	})
+
+	When("a config map the lrp mounts has been deleted", func() {
+		var configMap *corev1.ConfigMap
+
+		BeforeEach(func() {
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: "app-config-", Namespace: "default"},
+			}
+			Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+
+			lrp.Finalizers = []string{"eirini.cloudfoundry.org/test"}
+			lrp.Spec.VolumeMounts = []VolumeMount{
+				{MountPath: "/etc/app", ConfigMap: &ConfigMapVolumeSource{Name: configMap.Name}},
+			}
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+
+			Expect(k8sClient.Delete(ctx, configMap)).To(Succeed())
+		})
+
+		It("accepts updates that leave the volume mounts alone", func() {
+			lrp.Spec.Instances = 3
+			Expect(k8sClient.Update(ctx, lrp)).To(Succeed())
+		})
+
+		It("rejects updates that change the volume mounts", func() {
+			lrp.Spec.VolumeMounts[0].ReadOnly = true
+			err := k8sClient.Update(ctx, lrp)
+			Expect(err).To(MatchError(ContainSubstring("spec.volumeMounts[0].configMap.name: Not found")))
+		})
+
+		It("lets the lrp be deleted", func() {
+			Expect(k8sClient.Delete(ctx, lrp)).To(Succeed())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(lrp), lrp)).To(Succeed())
+			lrp.Finalizers = nil
+			Expect(k8sClient.Update(ctx, lrp)).To(Succeed())
+		})
+	})
+
+	When("an lrp created before a rule was added is updated without changing its spec", func() {
+		It("accepts the update", func() {
+			lrp.Spec.MemoryMB = 0
+			old := lrp.DeepCopy()
+			lrp.Finalizers = []string{"eirini.cloudfoundry.org/test"}
+
+			Expect(lrp.ValidateUpdate(old)).To(Succeed())
+		})
+	})
+
+	When("the lrp leaves fields with defaults empty", func() {
+		BeforeEach(func() {
+			lrp.Spec.DiskMB = 0
+			lrp.Spec.MemoryMB = 1024
+			lrp.Spec.Health = Healthcheck{}
+		})
+
+		It("fills them in", func() {
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+
+			Expect(lrp.Spec.ProcessType).To(Equal("web"))
+			Expect(lrp.Spec.DiskMB).To(BeEquivalentTo(1024))
+			Expect(lrp.Spec.CPUWeight).To(BeEquivalentTo(12))
+			Expect(lrp.Spec.LastUpdated).NotTo(BeEmpty())
+			Expect(lrp.Spec.Health.Type).To(Equal(HealthcheckTypePort))
+			Expect(lrp.Spec.Health.Port).To(BeEquivalentTo(8080))
+			Expect(lrp.Spec.Health.TimeoutMs).To(BeEquivalentTo(60000))
+		})
+
+		It("leaves them alone on update", func() {
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+
+			lrp.Spec.CPUWeight = 0
+			lrp.Spec.Health.TimeoutMs = 0
+			Expect(k8sClient.Update(ctx, lrp)).To(Succeed())
+
+			Expect(lrp.Spec.CPUWeight).To(BeZero())
+			Expect(lrp.Spec.Health.TimeoutMs).To(BeZero())
+		})
+	})
+})
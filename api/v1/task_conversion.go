@@ -0,0 +1,5 @@
+package v1
+
+// Hub marks v1 as the version the other versions of Task are converted
+// through
+func (*Task) Hub() {}
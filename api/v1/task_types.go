@@ -29,9 +29,18 @@ type TaskSpec struct {
 	GUID string `json:"GUID"`
 	Name string `json:"name"`
 	// +kubebuilder:validation:Required
-	Image           string            `json:"image"`
-	PrivateRegistry *PrivateRegistry  `json:"privateRegistry,omitempty"`
-	Env             map[string]string `json:"env,omitempty"`
+	Image string `json:"image"`
+	// CompletionCallback is the URL the controller POSTs to once the task
+	// has completed
+	CompletionCallback string            `json:"completionCallback,omitempty"`
+	PrivateRegistry    *PrivateRegistry  `json:"privateRegistry,omitempty"`
+	Env                map[string]string `json:"env,omitempty"`
+	// SecretEnv sets environment variables from keys of Secrets in the
+	// namespace of the task
+	SecretEnv []SecretEnvVar `json:"secretEnv,omitempty"`
+	// EnvInSecret moves Env into a Secret owned by the task, which the task
+	// references instead of carrying the values in its pod template
+	EnvInSecret bool `json:"envInSecret,omitempty"`
 	// +kubebuilder:validation:Required
 	Command   []string `json:"command,omitempty"`
 	AppName   string   `json:"appName"`
@@ -43,7 +52,22 @@ type TaskSpec struct {
 	MemoryMB  int64    `json:"memoryMB"`
 	DiskMB    int64    `json:"diskMB"`
 	// +kubebuilder:validation:Format:=uint8
-	CPUWeight uint8 `json:"cpuWeight"`
+	CPUWeight    uint8         `json:"cpuWeight"`
+	VolumeMounts []VolumeMount `json:"volumeMounts,omitempty"`
+	// TTLSecondsAfterFinished overrides how long the controller keeps the
+	// task around after it has succeeded or failed
+	// +kubebuilder:validation:Minimum:=0
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+	// TimeoutSeconds is how long the task may run before it is stopped and
+	// marked as failed
+	// +kubebuilder:validation:Minimum:=1
+	TimeoutSeconds *int64 `json:"timeoutSeconds,omitempty"`
+	// MaxRetries is how many times a failed task is retried
+	// +kubebuilder:validation:Minimum:=0
+	MaxRetries *int32 `json:"maxRetries,omitempty"`
+	// Cancelled stops the task by deleting its job, while keeping the task
+	// itself around to record the outcome
+	Cancelled bool `json:"cancelled,omitempty"`
 }
 
 type ExecutionStatus string
@@ -53,18 +77,57 @@ const (
 	TaskRunning   ExecutionStatus = "running"
 	TaskSucceeded ExecutionStatus = "succeeded"
 	TaskFailed    ExecutionStatus = "failed"
+	TaskCancelled ExecutionStatus = "cancelled"
 )
 
+const (
+	TaskConditionScheduled = "Scheduled"
+	TaskConditionRunning   = "Running"
+	TaskConditionComplete  = "Complete"
+	TaskConditionFailed    = "Failed"
+)
+
+type CallbackDeliveryState string
+
+const (
+	CallbackPending   CallbackDeliveryState = "pending"
+	CallbackDelivered CallbackDeliveryState = "delivered"
+	CallbackFailed    CallbackDeliveryState = "failed"
+)
+
+type CompletionCallbackStatus struct {
+	// +kubebuilder:validation:Enum=pending;delivered;failed
+	State           CallbackDeliveryState `json:"state"`
+	Attempts        int32                 `json:"attempts"`
+	LastAttemptTime *metav1.Time          `json:"last_attempt_time,omitempty"`
+	LastError       string                `json:"last_error,omitempty"`
+}
+
 type TaskStatus struct {
 	StartTime *metav1.Time `json:"start_time"`
 	EndTime   *metav1.Time `json:"end_time"`
-	// +kubebuilder:validation:Enum=starting;running;succeeded;failed
+	// +kubebuilder:validation:Enum=starting;running;succeeded;failed;cancelled
 	// +kubebuilder:default=starting
 	ExecutionStatus ExecutionStatus `json:"execution_status"`
+	// ExitCode, TerminationReason and TerminationMessage are read from the
+	// task container once it has terminated
+	ExitCode           *int32 `json:"exit_code,omitempty"`
+	TerminationReason  string `json:"termination_reason,omitempty"`
+	TerminationMessage string `json:"termination_message,omitempty"`
+	// FailureReason explains why the task failed, or why it cannot start
+	FailureReason      string                    `json:"failure_reason,omitempty"`
+	CompletionCallback *CompletionCallbackStatus `json:"completion_callback,omitempty"`
+	// ObservedGeneration and Conditions follow the Kubernetes API conventions
+	// so that generic tooling can tell the state of the task
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:storageversion
 
 // Task is the Schema for the tasks API
 type Task struct {
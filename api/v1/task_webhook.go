@@ -0,0 +1,178 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var tasklog = logf.Log.WithName("task-resource")
+
+func (r *Task) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	webhookReader = mgr.GetAPIReader()
+
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// applyDefaults is called by the TaskDefaulter
+func (r *Task) applyDefaults(defaults Defaults) {
+	tasklog.Info("default", "name", r.Name)
+
+	if r.Spec.DiskMB == 0 {
+		r.Spec.DiskMB = defaults.DiskMB
+	}
+
+	if r.Spec.CPUWeight == 0 {
+		r.Spec.CPUWeight = defaults.cpuWeight(r.Spec.MemoryMB)
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-eirini-cloudfoundry-org-v1-task,mutating=false,failurePolicy=fail,sideEffects=None,groups=eirini.cloudfoundry.org,resources=tasks,verbs=create;update,versions=v1,name=vtask.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &Task{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Task) ValidateCreate() error {
+	tasklog.Info("validate create", "name", r.Name)
+
+	allErrs := r.validateSpec()
+	allErrs = append(allErrs, r.validateUniqueGUID()...)
+
+	return r.toInvalid(allErrs)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+// Only the immutability of the spec is checked, so Tasks created before a
+// rule was added, or whose volumes have gone, can still be finalized.
+func (r *Task) ValidateUpdate(old runtime.Object) error {
+	tasklog.Info("validate update", "name", r.Name)
+
+	oldTask, ok := old.(*Task)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a Task but got a %T", old))
+	}
+
+	if r.DeletionTimestamp != nil {
+		return nil
+	}
+
+	return r.toInvalid(r.validateImmutableSpec(oldTask))
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Task) ValidateDelete() error {
+	tasklog.Info("validate delete", "name", r.Name)
+
+	return nil
+}
+
+func (r *Task) toInvalid(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(GroupVersion.WithKind("Task").GroupKind(), r.Name, allErrs)
+}
+
+func (r *Task) validateSpec() field.ErrorList {
+	var allErrs field.ErrorList
+
+	specPath := field.NewPath("spec")
+
+	if r.Spec.GUID == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("GUID"), ""))
+	}
+
+	if r.Spec.Image == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("image"), ""))
+	}
+
+	if len(r.Spec.Command) == 0 || r.Spec.Command[0] == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("command"), "must start with the executable to run"))
+	}
+
+	if r.Spec.MemoryMB <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("memoryMB"), r.Spec.MemoryMB, "must be greater than 0"))
+	}
+
+	if r.Spec.DiskMB <= 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("diskMB"), r.Spec.DiskMB, "must be greater than 0"))
+	}
+
+	allErrs = append(allErrs, validateEnv(specPath.Child("env"), r.Spec.Env)...)
+	allErrs = append(allErrs, validateSecretEnv(specPath.Child("secretEnv"), r.Spec.SecretEnv)...)
+	allErrs = append(allErrs, validateVolumeMounts(r.Namespace, specPath.Child("volumeMounts"), r.Spec.VolumeMounts, true)...)
+
+	return allErrs
+}
+
+// validateImmutableSpec only lets a Task be cancelled, or have its TTL
+// changed, once it has been created. Anything else would not be reflected
+// in its Job.
+func (r *Task) validateImmutableSpec(old *Task) field.ErrorList {
+	specPath := field.NewPath("spec")
+
+	if old.Spec.Cancelled && !r.Spec.Cancelled {
+		return field.ErrorList{field.Forbidden(specPath.Child("cancelled"), "a cancelled task cannot be resumed")}
+	}
+
+	spec := r.Spec.DeepCopy()
+	spec.Cancelled = old.Spec.Cancelled
+	spec.TTLSecondsAfterFinished = old.Spec.TTLSecondsAfterFinished
+
+	if !apiequality.Semantic.DeepEqual(*spec, old.Spec) {
+		return field.ErrorList{field.Forbidden(specPath, "only cancelled and ttlSecondsAfterFinished may be changed")}
+	}
+
+	return nil
+}
+
+// validateUniqueGUID rejects a Task whose GUID is already used in the
+// namespace, as the workload client finds Jobs by GUID
+func (r *Task) validateUniqueGUID() field.ErrorList {
+	if webhookReader == nil {
+		return nil
+	}
+
+	guidPath := field.NewPath("spec", "GUID")
+
+	tasks := &TaskList{}
+	if err := webhookReader.List(context.Background(), tasks, client.InNamespace(r.Namespace)); err != nil {
+		return field.ErrorList{field.InternalError(guidPath, err)}
+	}
+
+	for _, task := range tasks.Items {
+		if task.Spec.GUID == r.Spec.GUID && task.Name != r.Name {
+			return field.ErrorList{field.Duplicate(guidPath, r.Spec.GUID)}
+		}
+	}
+
+	return nil
+}
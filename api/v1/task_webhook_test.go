@@ -0,0 +1,164 @@
+package v1
+
+import (
+	uuid "github.com/hashicorp/go-uuid"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Task Webhook", func() {
+	var task *Task
+
+	BeforeEach(func() {
+		guid, err := uuid.GenerateUUID()
+		Expect(err).NotTo(HaveOccurred())
+
+		task = &Task{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "task-",
+				Namespace:    "default",
+			},
+			Spec: TaskSpec{
+				GUID:     guid,
+				Image:    "eirini/busybox",
+				Command:  []string{"sh", "-c", "echo hi"},
+				MemoryMB: 256,
+			},
+		}
+	})
+
+	It("fills in the disk quota and cpu weight", func() {
+		Expect(k8sClient.Create(ctx, task)).To(Succeed())
+
+		Expect(task.Spec.DiskMB).To(BeEquivalentTo(1024))
+		Expect(task.Spec.CPUWeight).To(BeEquivalentTo(3))
+	})
+
+	When("the task sets a disk quota and cpu weight", func() {
+		BeforeEach(func() {
+			task.Spec.DiskMB = 64
+			task.Spec.CPUWeight = 50
+		})
+
+		It("keeps them", func() {
+			Expect(k8sClient.Create(ctx, task)).To(Succeed())
+
+			Expect(task.Spec.DiskMB).To(BeEquivalentTo(64))
+			Expect(task.Spec.CPUWeight).To(BeEquivalentTo(50))
+		})
+	})
+
+	When("the image is empty", func() {
+		BeforeEach(func() {
+			task.Spec.Image = ""
+		})
+
+		It("rejects the task", func() {
+			err := k8sClient.Create(ctx, task)
+			Expect(err).To(MatchError(ContainSubstring("spec.image")))
+		})
+	})
+
+	When("the command is empty", func() {
+		BeforeEach(func() {
+			task.Spec.Command = []string{""}
+		})
+
+		It("rejects the task", func() {
+			err := k8sClient.Create(ctx, task)
+			Expect(err).To(MatchError(ContainSubstring("spec.command")))
+		})
+	})
+
+	When("the memory is negative", func() {
+		BeforeEach(func() {
+			task.Spec.MemoryMB = -1
+		})
+
+		It("rejects the task", func() {
+			err := k8sClient.Create(ctx, task)
+			Expect(err).To(MatchError(ContainSubstring("spec.memoryMB")))
+		})
+	})
+
+	When("another task in the namespace has the same guid", func() {
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, task.DeepCopy())).To(Succeed())
+		})
+
+		It("rejects the task", func() {
+			err := k8sClient.Create(ctx, task)
+			Expect(err).To(MatchError(ContainSubstring("spec.GUID: Duplicate value")))
+		})
+	})
+
+	When("the task has been created", func() {
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, task)).To(Succeed())
+		})
+
+		It("can be cancelled", func() {
+			task.Spec.Cancelled = true
+			Expect(k8sClient.Update(ctx, task)).To(Succeed())
+		})
+
+		It("rejects other changes to the spec", func() {
+			task.Spec.Image = "eirini/dorini"
+			err := k8sClient.Update(ctx, task)
+			Expect(err).To(MatchError(ContainSubstring("only cancelled and ttlSecondsAfterFinished may be changed")))
+		})
+
+		It("does not default the spec again", func() {
+			task.Spec.DiskMB = 0
+			task.Spec.Cancelled = true
+			err := k8sClient.Update(ctx, task)
+			Expect(err).To(MatchError(ContainSubstring("only cancelled and ttlSecondsAfterFinished may be changed")))
+		})
+	})
+
+	When("a config map the task mounts has been deleted", func() {
+		var configMap *corev1.ConfigMap
+
+		BeforeEach(func() {
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: "task-config-", Namespace: "default"},
+			}
+			Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+
+			task.Spec.VolumeMounts = []VolumeMount{
+				{MountPath: "/etc/task", ConfigMap: &ConfigMapVolumeSource{Name: configMap.Name}},
+			}
+			Expect(k8sClient.Create(ctx, task)).To(Succeed())
+
+			Expect(k8sClient.Delete(ctx, configMap)).To(Succeed())
+		})
+
+		It("accepts a finalizer being added", func() {
+			task.Finalizers = []string{"eirini.cloudfoundry.org/test"}
+			Expect(k8sClient.Update(ctx, task)).To(Succeed())
+		})
+
+		It("lets the task be deleted", func() {
+			task.Finalizers = []string{"eirini.cloudfoundry.org/test"}
+			Expect(k8sClient.Update(ctx, task)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, task)).To(Succeed())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(task), task)).To(Succeed())
+			task.Finalizers = nil
+			Expect(k8sClient.Update(ctx, task)).To(Succeed())
+		})
+	})
+
+	When("a task created before a rule was added is updated without changing its spec", func() {
+		It("accepts the update", func() {
+			task.Spec.MemoryMB = -1
+			old := task.DeepCopy()
+			task.Finalizers = []string{"eirini.cloudfoundry.org/test"}
+
+			Expect(task.ValidateUpdate(old)).To(Succeed())
+		})
+	})
+})
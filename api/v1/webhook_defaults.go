@@ -0,0 +1,132 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	LRPDefaulterPath  = "/mutate-eirini-cloudfoundry-org-v1-lrp"
+	TaskDefaulterPath = "/mutate-eirini-cloudfoundry-org-v1-task"
+)
+
+// Defaults are the values the defaulting webhooks fill in for the fields
+// LRPs and Tasks leave empty
+type Defaults struct {
+	// HealthcheckType is the check given to LRPs that expose ports. LRPs
+	// without ports get a process check.
+	HealthcheckType      string
+	HealthcheckTimeoutMs uint
+	DiskMB               int64
+	// CPUWeightMaxMemoryMB is the memory that gets the full CPU weight of
+	// 100. Smaller workloads get a share of it in proportion to their
+	// memory.
+	CPUWeightMaxMemoryMB int64
+	ProcessType          string
+}
+
+// BuiltinDefaults are the defaults used when the controller configuration
+// does not override them
+func BuiltinDefaults() Defaults {
+	return Defaults{
+		HealthcheckType:      HealthcheckTypePort,
+		HealthcheckTimeoutMs: 60000,
+		DiskMB:               1024,
+		CPUWeightMaxMemoryMB: 8192,
+		ProcessType:          "web",
+	}
+}
+
+func (d Defaults) cpuWeight(memoryMB int64) uint8 {
+	if d.CPUWeightMaxMemoryMB <= 0 {
+		return 0
+	}
+
+	weight := memoryMB * 100 / d.CPUWeightMaxMemoryMB
+
+	switch {
+	case weight < 1:
+		return 1
+	case weight > 100:
+		return 100
+	default:
+		return uint8(weight)
+	}
+}
+
+//+kubebuilder:webhook:path=/mutate-eirini-cloudfoundry-org-v1-lrp,mutating=true,failurePolicy=fail,sideEffects=None,groups=eirini.cloudfoundry.org,resources=lrps,verbs=create,versions=v1,name=mlrp.kb.io,admissionReviewVersions={v1,v1beta1}
+
+// LRPDefaulter fills in the fields new LRPs leave empty. Existing LRPs are
+// left alone, as defaulting a field they have been running without would
+// restart their instances.
+// +kubebuilder:object:generate=false
+type LRPDefaulter struct {
+	Defaults Defaults
+
+	decoder *admission.Decoder
+}
+
+func (d *LRPDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create {
+		return admission.Allowed("only new lrps are defaulted")
+	}
+
+	lrp := &LRP{}
+
+	return defaultObject(req, d.decoder, lrp, func() { lrp.applyDefaults(d.Defaults) })
+}
+
+// InjectDecoder implements admission.DecoderInjector
+func (d *LRPDefaulter) InjectDecoder(decoder *admission.Decoder) error {
+	d.decoder = decoder
+
+	return nil
+}
+
+//+kubebuilder:webhook:path=/mutate-eirini-cloudfoundry-org-v1-task,mutating=true,failurePolicy=fail,sideEffects=None,groups=eirini.cloudfoundry.org,resources=tasks,verbs=create,versions=v1,name=mtask.kb.io,admissionReviewVersions={v1,v1beta1}
+
+// TaskDefaulter fills in the fields new Tasks leave empty. Existing Tasks are
+// left alone, as their spec may only be changed to cancel them.
+// +kubebuilder:object:generate=false
+type TaskDefaulter struct {
+	Defaults Defaults
+
+	decoder *admission.Decoder
+}
+
+func (d *TaskDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create {
+		return admission.Allowed("only new tasks are defaulted")
+	}
+
+	task := &Task{}
+
+	return defaultObject(req, d.decoder, task, func() { task.applyDefaults(d.Defaults) })
+}
+
+// InjectDecoder implements admission.DecoderInjector
+func (d *TaskDefaulter) InjectDecoder(decoder *admission.Decoder) error {
+	d.decoder = decoder
+
+	return nil
+}
+
+func defaultObject(req admission.Request, decoder *admission.Decoder, obj runtime.Object, applyDefaults func()) admission.Response {
+	if err := decoder.Decode(req, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	applyDefaults()
+
+	marshaled, err := json.Marshal(obj)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
@@ -38,6 +38,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
 // These tests use Ginkgo (BDD-style Go testing framework). Refer to
@@ -103,6 +104,12 @@ var _ = BeforeSuite(func() {
 	err = (&LRP{}).SetupWebhookWithManager(mgr)
 	Expect(err).NotTo(HaveOccurred())
 
+	err = (&Task{}).SetupWebhookWithManager(mgr)
+	Expect(err).NotTo(HaveOccurred())
+
+	mgr.GetWebhookServer().Register(LRPDefaulterPath, &webhook.Admission{Handler: &LRPDefaulter{Defaults: BuiltinDefaults()}})
+	mgr.GetWebhookServer().Register(TaskDefaulterPath, &webhook.Admission{Handler: &TaskDefaulter{Defaults: BuiltinDefaults()}})
+
 	//+kubebuilder:scaffold:webhook
 
 	go func() {
@@ -0,0 +1,286 @@
+package v2
+
+import (
+	"encoding/json"
+	"time"
+
+	v1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// AnnotationConversionData holds a v2 spec that could not be converted to
+// v1 without losing something, such as port names. Converting the object
+// back to v2 restores the spec from it, unless a v1 client has changed the
+// spec in the meantime. Values the controller would run differently, such as
+// quantities that are not whole megabytes, are rejected by the v2 webhooks
+// instead, see validateResources.
+const AnnotationConversionData = "eirini.cloudfoundry.org/v2-conversion-data"
+
+func storeConversionData(meta *metav1.ObjectMeta, spec interface{}) error {
+	specJSON, err := json.Marshal(spec)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal conversion data")
+	}
+
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+
+	meta.Annotations[AnnotationConversionData] = string(specJSON)
+
+	return nil
+}
+
+func restoreConversionData(meta *metav1.ObjectMeta, spec interface{}) (bool, error) {
+	specJSON, ok := meta.Annotations[AnnotationConversionData]
+	if !ok {
+		return false, nil
+	}
+
+	deleteConversionData(meta)
+
+	return true, errors.Wrap(json.Unmarshal([]byte(specJSON), spec), "failed to unmarshal conversion data")
+}
+
+func deleteConversionData(meta *metav1.ObjectMeta) {
+	delete(meta.Annotations, AnnotationConversionData)
+
+	if len(meta.Annotations) == 0 {
+		meta.Annotations = nil
+	}
+}
+
+// v1 sizes are in megabytes and CPU weights in millicores, matching how the
+// StatefulSet and Job converters turn them into resource requirements
+
+func toMB(q resource.Quantity) int64 {
+	return q.ScaledValue(resource.Mega)
+}
+
+func fromMB(mb int64) resource.Quantity {
+	return *resource.NewScaledQuantity(mb, resource.Mega)
+}
+
+func toMBPtr(q *resource.Quantity) *int64 {
+	if q == nil {
+		return nil
+	}
+
+	mb := toMB(*q)
+
+	return &mb
+}
+
+func fromMBPtr(mb *int64) *resource.Quantity {
+	if mb == nil {
+		return nil
+	}
+
+	q := fromMB(*mb)
+
+	return &q
+}
+
+func toCPUWeight(q resource.Quantity) uint8 {
+	millis := q.MilliValue()
+
+	switch {
+	case millis < 0:
+		return 0
+	case millis > 255:
+		return 255
+	default:
+		return uint8(millis)
+	}
+}
+
+func fromCPUWeight(weight uint8) resource.Quantity {
+	return *resource.NewScaledQuantity(int64(weight), resource.Milli)
+}
+
+func toCPUWeightPtr(q *resource.Quantity) *uint8 {
+	if q == nil {
+		return nil
+	}
+
+	weight := toCPUWeight(*q)
+
+	return &weight
+}
+
+func fromCPUWeightPtr(weight *uint8) *resource.Quantity {
+	if weight == nil {
+		return nil
+	}
+
+	q := fromCPUWeight(*weight)
+
+	return &q
+}
+
+func privateRegistryToV1(in *PrivateRegistry) *v1.PrivateRegistry {
+	return (*v1.PrivateRegistry)(in)
+}
+
+func privateRegistryFromV1(in *v1.PrivateRegistry) *PrivateRegistry {
+	return (*PrivateRegistry)(in)
+}
+
+func secretEnvToV1(in []SecretEnvVar) []v1.SecretEnvVar {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]v1.SecretEnvVar, 0, len(in))
+	for _, env := range in {
+		out = append(out, v1.SecretEnvVar(env))
+	}
+
+	return out
+}
+
+func secretEnvFromV1(in []v1.SecretEnvVar) []SecretEnvVar {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]SecretEnvVar, 0, len(in))
+	for _, env := range in {
+		out = append(out, SecretEnvVar(env))
+	}
+
+	return out
+}
+
+func volumeMountsToV1(in []VolumeMount) []v1.VolumeMount {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]v1.VolumeMount, 0, len(in))
+	for _, mount := range in {
+		out = append(out, v1.VolumeMount{
+			MountPath: mount.MountPath,
+			ReadOnly:  mount.ReadOnly,
+			SubPath:   mount.SubPath,
+			ClaimName: mount.ClaimName,
+			ConfigMap: (*v1.ConfigMapVolumeSource)(mount.ConfigMap),
+			Secret:    (*v1.SecretVolumeSource)(mount.Secret),
+			EmptyDir:  (*v1.EmptyDirVolumeSource)(mount.EmptyDir),
+		})
+	}
+
+	return out
+}
+
+func volumeMountsFromV1(in []v1.VolumeMount) []VolumeMount {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]VolumeMount, 0, len(in))
+	for _, mount := range in {
+		out = append(out, VolumeMount{
+			MountPath: mount.MountPath,
+			ReadOnly:  mount.ReadOnly,
+			SubPath:   mount.SubPath,
+			ClaimName: mount.ClaimName,
+			ConfigMap: (*ConfigMapVolumeSource)(mount.ConfigMap),
+			Secret:    (*SecretVolumeSource)(mount.Secret),
+			EmptyDir:  (*EmptyDirVolumeSource)(mount.EmptyDir),
+		})
+	}
+
+	return out
+}
+
+func startTimeoutToV1(in *metav1.Duration) uint {
+	if in == nil {
+		return 0
+	}
+
+	return uint(in.Milliseconds())
+}
+
+func startTimeoutFromV1(timeoutMs uint) *metav1.Duration {
+	if timeoutMs == 0 {
+		return nil
+	}
+
+	return &metav1.Duration{Duration: time.Duration(timeoutMs) * time.Millisecond}
+}
+
+// The v2 webhooks reject the values v1 cannot represent, as the controller
+// only reads the v1 spec and would otherwise run something else than what
+// the v2 spec says
+
+func validateResources(path *field.Path, in Resources) field.ErrorList {
+	var allErrs field.ErrorList
+
+	allErrs = append(allErrs, validateMB(path.Child("memory"), in.Memory)...)
+	allErrs = append(allErrs, validateMB(path.Child("disk"), in.Disk)...)
+	allErrs = append(allErrs, validateCPUWeight(path.Child("cpu"), in.CPU)...)
+
+	return allErrs
+}
+
+func validateSidecarResources(path *field.Path, in SidecarResources) field.ErrorList {
+	allErrs := validateMB(path.Child("memory"), in.Memory)
+
+	if in.Disk != nil {
+		allErrs = append(allErrs, validateMB(path.Child("disk"), *in.Disk)...)
+	}
+
+	if in.CPU != nil {
+		allErrs = append(allErrs, validateCPUWeight(path.Child("cpu"), *in.CPU)...)
+	}
+
+	return allErrs
+}
+
+func validateMB(path *field.Path, q resource.Quantity) field.ErrorList {
+	mb := fromMB(toMB(q))
+	if mb.Cmp(q) != 0 {
+		return field.ErrorList{field.Invalid(path, q.String(), "must be a whole number of megabytes (M)")}
+	}
+
+	return nil
+}
+
+func validateCPUWeight(path *field.Path, q resource.Quantity) field.ErrorList {
+	weight := fromCPUWeight(toCPUWeight(q))
+	if weight.Cmp(q) != 0 {
+		return field.ErrorList{field.Invalid(path, q.String(), "must be a whole number of millicores between 0 and 255m")}
+	}
+
+	return nil
+}
+
+func validatePorts(path *field.Path, ports []Port) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for i, port := range ports {
+		if port.Protocol != "" && port.Protocol != corev1.ProtocolTCP {
+			allErrs = append(allErrs, field.NotSupported(path.Index(i).Child("protocol"), port.Protocol, []string{string(corev1.ProtocolTCP)}))
+		}
+	}
+
+	return allErrs
+}
+
+func validateHealthChecks(path *field.Path, in HealthChecks) field.ErrorList {
+	if in.StartTimeout == nil {
+		return nil
+	}
+
+	timeout := in.StartTimeout.Duration
+	if timeout < 0 || timeout%time.Millisecond != 0 {
+		return field.ErrorList{field.Invalid(path.Child("startTimeout"), in.StartTimeout.String(), "must be a whole number of milliseconds greater than or equal to 0")}
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"math/rand"
+	"time"
+
+	v1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	fuzz "github.com/google/gofuzz"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/diff"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+const fuzzIterations = 1000
+
+func newFuzzer() *fuzz.Fuzzer {
+	return fuzz.New().
+		NilChance(0.2).
+		RandSource(rand.NewSource(GinkgoRandomSeed())).
+		Funcs(
+			func(q *resource.Quantity, c fuzz.Continue) {
+				*q = *resource.NewMilliQuantity(c.Int63n(1<<40), resource.DecimalSI)
+			},
+			// the API server stores times with second precision
+			func(t *metav1.Time, c fuzz.Continue) {
+				*t = metav1.Unix(c.Int63n(1<<32), 0)
+			},
+			func(d *metav1.Duration, c fuzz.Continue) {
+				d.Duration = time.Duration(c.Int63n(int64(time.Hour)))
+			},
+			// the type meta is set by the scheme, not by the conversion
+			func(t *metav1.TypeMeta, c fuzz.Continue) {},
+			// v1 timeouts have to fit in a v2 duration
+			func(h *v1.Healthcheck, c fuzz.Continue) {
+				c.FuzzNoCustom(h)
+				h.TimeoutMs = uint(c.Uint32())
+			},
+		)
+}
+
+func expectSemanticallyEqual(actual, expected interface{}) {
+	ExpectWithOffset(1, apiequality.Semantic.DeepEqual(actual, expected)).To(BeTrue(), diff.ObjectReflectDiff(expected, actual))
+}
+
+var _ = Describe("Conversion", func() {
+	var fuzzer *fuzz.Fuzzer
+
+	BeforeEach(func() {
+		fuzzer = newFuzzer()
+	})
+
+	roundTripsThroughHub := func(newSpoke func() conversion.Convertible, newHub func() conversion.Hub) {
+		for i := 0; i < fuzzIterations; i++ {
+			original := newSpoke()
+			fuzzer.Fuzz(original)
+
+			hub := newHub()
+			Expect(original.ConvertTo(hub)).To(Succeed())
+
+			converted := newSpoke()
+			Expect(converted.ConvertFrom(hub)).To(Succeed())
+
+			expectSemanticallyEqual(converted, original)
+		}
+	}
+
+	roundTripsThroughSpoke := func(newHub func() conversion.Hub, newSpoke func() conversion.Convertible) {
+		for i := 0; i < fuzzIterations; i++ {
+			original := newHub()
+			fuzzer.Fuzz(original)
+
+			spoke := newSpoke()
+			Expect(spoke.ConvertFrom(original)).To(Succeed())
+
+			converted := newHub()
+			Expect(spoke.ConvertTo(converted)).To(Succeed())
+
+			expectSemanticallyEqual(converted, original)
+		}
+	}
+
+	Describe("LRP", func() {
+		newLRP := func() conversion.Convertible { return &LRP{} }
+		newV1LRP := func() conversion.Hub { return &v1.LRP{} }
+
+		It("round-trips v2 through v1 without losing anything", func() {
+			roundTripsThroughHub(newLRP, newV1LRP)
+		})
+
+		It("round-trips v1 through v2 without losing anything", func() {
+			roundTripsThroughSpoke(newV1LRP, newLRP)
+		})
+
+		It("converts v1 sizes to resource quantities", func() {
+			lrp := &LRP{}
+			Expect(lrp.ConvertFrom(&v1.LRP{Spec: v1.LRPSpec{MemoryMB: 256, DiskMB: 1024, CPUWeight: 40}})).To(Succeed())
+
+			Expect(lrp.Spec.Resources.Memory.String()).To(Equal("256M"))
+			Expect(lrp.Spec.Resources.Disk.String()).To(Equal("1024M"))
+			Expect(lrp.Spec.Resources.CPU.String()).To(Equal("40m"))
+		})
+
+		It("converts the v1 health check to a liveness probe and start timeout", func() {
+			lrp := &LRP{}
+			Expect(lrp.ConvertFrom(&v1.LRP{Spec: v1.LRPSpec{Health: v1.Healthcheck{
+				Type:      HealthCheckTypePort,
+				Port:      8080,
+				TimeoutMs: 60000,
+			}}})).To(Succeed())
+
+			Expect(lrp.Spec.HealthChecks.Liveness).To(Equal(&Probe{Type: HealthCheckTypePort, Port: 8080}))
+			Expect(lrp.Spec.HealthChecks.Readiness).To(BeNil())
+			Expect(lrp.Spec.HealthChecks.StartTimeout).To(Equal(&metav1.Duration{Duration: time.Minute}))
+		})
+
+		When("the v2 spec cannot be expressed in v1", func() {
+			var v1LRP *v1.LRP
+
+			BeforeEach(func() {
+				v1LRP = &v1.LRP{}
+				lrp := &LRP{Spec: LRPSpec{
+					Ports:     []Port{{Name: "http", ContainerPort: 8080, Protocol: corev1.ProtocolTCP}},
+					Resources: Resources{Memory: resource.MustParse("256Mi")},
+				}}
+
+				Expect(lrp.ConvertTo(v1LRP)).To(Succeed())
+			})
+
+			It("converts it as closely as possible", func() {
+				Expect(v1LRP.Spec.Ports).To(Equal([]int32{8080}))
+				Expect(v1LRP.Spec.MemoryMB).To(Equal(int64(269)))
+			})
+
+			It("keeps the v2 spec in an annotation", func() {
+				Expect(v1LRP.Annotations).To(HaveKey(AnnotationConversionData))
+
+				lrp := &LRP{}
+				Expect(lrp.ConvertFrom(v1LRP)).To(Succeed())
+
+				Expect(lrp.Annotations).NotTo(HaveKey(AnnotationConversionData))
+				Expect(lrp.Spec.Ports).To(Equal([]Port{{Name: "http", ContainerPort: 8080, Protocol: corev1.ProtocolTCP}}))
+				Expect(lrp.Spec.Resources.Memory.String()).To(Equal("256Mi"))
+			})
+
+			It("ignores the annotation once a v1 client has changed the spec", func() {
+				v1LRP.Spec.Ports = []int32{9090}
+
+				lrp := &LRP{}
+				Expect(lrp.ConvertFrom(v1LRP)).To(Succeed())
+
+				Expect(lrp.Annotations).NotTo(HaveKey(AnnotationConversionData))
+				Expect(lrp.Spec.Ports).To(Equal([]Port{{ContainerPort: 9090}}))
+				Expect(lrp.Spec.Resources.Memory.String()).To(Equal("269M"))
+			})
+		})
+	})
+
+	Describe("Task", func() {
+		newTask := func() conversion.Convertible { return &Task{} }
+		newV1Task := func() conversion.Hub { return &v1.Task{} }
+
+		It("round-trips v2 through v1 without losing anything", func() {
+			roundTripsThroughHub(newTask, newV1Task)
+		})
+
+		It("round-trips v1 through v2 without losing anything", func() {
+			roundTripsThroughSpoke(newV1Task, newTask)
+		})
+
+		It("converts v1 sizes to resource quantities", func() {
+			task := &Task{}
+			Expect(task.ConvertFrom(&v1.Task{Spec: v1.TaskSpec{MemoryMB: 512, DiskMB: 2048, CPUWeight: 10}})).To(Succeed())
+
+			Expect(task.Spec.Resources.Memory.String()).To(Equal("512M"))
+			Expect(task.Spec.Resources.Disk.String()).To(Equal("2048M"))
+			Expect(task.Spec.Resources.CPU.String()).To(Equal("10m"))
+		})
+	})
+})
@@ -0,0 +1,384 @@
+package v2
+
+import (
+	"fmt"
+
+	v1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// ConvertTo converts this LRP to the hub version (v1)
+func (src *LRP) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1.LRP)
+	if !ok {
+		return fmt.Errorf("unsupported hub %T", dstRaw)
+	}
+
+	in := src.DeepCopy()
+
+	dst.ObjectMeta = in.ObjectMeta
+	dst.Spec = lrpSpecToV1(in.Spec)
+	dst.Status = lrpStatusToV1(in.Status)
+
+	deleteConversionData(&dst.ObjectMeta)
+
+	if apiequality.Semantic.DeepEqual(lrpSpecFromV1(*dst.Spec.DeepCopy()), src.Spec) {
+		return nil
+	}
+
+	return storeConversionData(&dst.ObjectMeta, src.Spec)
+}
+
+// ConvertFrom converts from the hub version (v1) to this version
+func (dst *LRP) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1.LRP)
+	if !ok {
+		return fmt.Errorf("unsupported hub %T", srcRaw)
+	}
+
+	in := src.DeepCopy()
+
+	dst.ObjectMeta = in.ObjectMeta
+	dst.Spec = lrpSpecFromV1(in.Spec)
+	dst.Status = lrpStatusFromV1(in.Status)
+
+	stored := LRPSpec{}
+
+	restored, err := restoreConversionData(&dst.ObjectMeta, &stored)
+	if err != nil {
+		return err
+	}
+
+	if restored && apiequality.Semantic.DeepEqual(lrpSpecToV1(*stored.DeepCopy()), src.Spec) {
+		dst.Spec = stored
+	}
+
+	return nil
+}
+
+func lrpSpecToV1(in LRPSpec) v1.LRPSpec {
+	return v1.LRPSpec{
+		GUID:                   in.GUID,
+		Version:                in.Version,
+		ProcessType:            in.ProcessType,
+		AppName:                in.AppName,
+		AppGUID:                in.AppGUID,
+		OrgName:                in.OrgName,
+		OrgGUID:                in.OrgGUID,
+		SpaceName:              in.SpaceName,
+		SpaceGUID:              in.SpaceGUID,
+		Image:                  in.Image,
+		Command:                in.Command,
+		Sidecars:               sidecarsToV1(in.Sidecars),
+		PrivateRegistry:        privateRegistryToV1(in.PrivateRegistry),
+		Env:                    in.Env,
+		SecretEnv:              secretEnvToV1(in.SecretEnv),
+		EnvInSecret:            in.EnvInSecret,
+		Health:                 healthChecksToV1(in.HealthChecks),
+		Ports:                  portsToV1(in.Ports),
+		Routes:                 routesToV1(in.Routes),
+		Instances:              in.Instances,
+		MemoryMB:               toMB(in.Resources.Memory),
+		DiskMB:                 toMB(in.Resources.Disk),
+		CPUWeight:              toCPUWeight(in.Resources.CPU),
+		VolumeMounts:           volumeMountsToV1(in.VolumeMounts),
+		LastUpdated:            in.LastUpdated,
+		UserDefinedAnnotations: in.UserDefinedAnnotations,
+		InstanceRestarts:       instanceRestartsToV1(in.InstanceRestarts),
+		Autoscaling:            (*v1.Autoscaling)(in.Autoscaling),
+	}
+}
+
+func lrpSpecFromV1(in v1.LRPSpec) LRPSpec {
+	return LRPSpec{
+		GUID:            in.GUID,
+		Version:         in.Version,
+		ProcessType:     in.ProcessType,
+		AppName:         in.AppName,
+		AppGUID:         in.AppGUID,
+		OrgName:         in.OrgName,
+		OrgGUID:         in.OrgGUID,
+		SpaceName:       in.SpaceName,
+		SpaceGUID:       in.SpaceGUID,
+		Image:           in.Image,
+		Command:         in.Command,
+		Sidecars:        sidecarsFromV1(in.Sidecars),
+		PrivateRegistry: privateRegistryFromV1(in.PrivateRegistry),
+		Env:             in.Env,
+		SecretEnv:       secretEnvFromV1(in.SecretEnv),
+		EnvInSecret:     in.EnvInSecret,
+		HealthChecks:    healthChecksFromV1(in.Health),
+		Ports:           portsFromV1(in.Ports),
+		Routes:          routesFromV1(in.Routes),
+		Instances:       in.Instances,
+		Resources: Resources{
+			Memory: fromMB(in.MemoryMB),
+			Disk:   fromMB(in.DiskMB),
+			CPU:    fromCPUWeight(in.CPUWeight),
+		},
+		VolumeMounts:           volumeMountsFromV1(in.VolumeMounts),
+		LastUpdated:            in.LastUpdated,
+		UserDefinedAnnotations: in.UserDefinedAnnotations,
+		InstanceRestarts:       instanceRestartsFromV1(in.InstanceRestarts),
+		Autoscaling:            (*Autoscaling)(in.Autoscaling),
+	}
+}
+
+// healthChecksToV1 folds the liveness probe and start timeout into the flat
+// v1 health check
+func healthChecksToV1(in HealthChecks) v1.Healthcheck {
+	out := v1.Healthcheck{
+		TimeoutMs: startTimeoutToV1(in.StartTimeout),
+		Readiness: (*v1.ReadinessCheck)(in.Readiness),
+	}
+
+	if in.Liveness != nil {
+		out.Type = in.Liveness.Type
+		out.Port = in.Liveness.Port
+		out.Endpoint = in.Liveness.Endpoint
+		out.InvocationTimeoutSeconds = in.Liveness.InvocationTimeoutSeconds
+		out.PeriodSeconds = in.Liveness.PeriodSeconds
+		out.FailureThreshold = in.Liveness.FailureThreshold
+	}
+
+	return out
+}
+
+// healthChecksFromV1 only sets a liveness probe when the v1 health check
+// sets any of its fields
+func healthChecksFromV1(in v1.Healthcheck) HealthChecks {
+	out := HealthChecks{
+		StartTimeout: startTimeoutFromV1(in.TimeoutMs),
+		Readiness:    (*Probe)(in.Readiness),
+	}
+
+	liveness := Probe{
+		Type:                     in.Type,
+		Port:                     in.Port,
+		Endpoint:                 in.Endpoint,
+		InvocationTimeoutSeconds: in.InvocationTimeoutSeconds,
+		PeriodSeconds:            in.PeriodSeconds,
+		FailureThreshold:         in.FailureThreshold,
+	}
+
+	if !apiequality.Semantic.DeepEqual(liveness, Probe{}) {
+		out.Liveness = &liveness
+	}
+
+	return out
+}
+
+func healthChecksToV1Ptr(in *HealthChecks) *v1.Healthcheck {
+	if in == nil {
+		return nil
+	}
+
+	out := healthChecksToV1(*in)
+
+	return &out
+}
+
+func healthChecksFromV1Ptr(in *v1.Healthcheck) *HealthChecks {
+	if in == nil {
+		return nil
+	}
+
+	out := healthChecksFromV1(*in)
+
+	return &out
+}
+
+func portsToV1(in []Port) []int32 {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]int32, 0, len(in))
+	for _, port := range in {
+		out = append(out, port.ContainerPort)
+	}
+
+	return out
+}
+
+func portsFromV1(in []int32) []Port {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]Port, 0, len(in))
+	for _, port := range in {
+		out = append(out, Port{ContainerPort: port})
+	}
+
+	return out
+}
+
+func routesToV1(in []Route) []v1.Route {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]v1.Route, 0, len(in))
+	for _, route := range in {
+		out = append(out, v1.Route(route))
+	}
+
+	return out
+}
+
+func routesFromV1(in []v1.Route) []Route {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]Route, 0, len(in))
+	for _, route := range in {
+		out = append(out, Route(route))
+	}
+
+	return out
+}
+
+func sidecarsToV1(in []Sidecar) []v1.Sidecar {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]v1.Sidecar, 0, len(in))
+	for _, sidecar := range in {
+		out = append(out, v1.Sidecar{
+			Name:      sidecar.Name,
+			Command:   sidecar.Command,
+			MemoryMB:  toMB(sidecar.Resources.Memory),
+			Env:       sidecar.Env,
+			Image:     sidecar.Image,
+			Ports:     portsToV1(sidecar.Ports),
+			CPUWeight: toCPUWeightPtr(sidecar.Resources.CPU),
+			DiskMB:    toMBPtr(sidecar.Resources.Disk),
+			Health:    healthChecksToV1Ptr(sidecar.HealthChecks),
+			Start:     sidecar.Start,
+		})
+	}
+
+	return out
+}
+
+func sidecarsFromV1(in []v1.Sidecar) []Sidecar {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]Sidecar, 0, len(in))
+	for _, sidecar := range in {
+		out = append(out, Sidecar{
+			Name:    sidecar.Name,
+			Command: sidecar.Command,
+			Env:     sidecar.Env,
+			Image:   sidecar.Image,
+			Ports:   portsFromV1(sidecar.Ports),
+			Resources: SidecarResources{
+				Memory: fromMB(sidecar.MemoryMB),
+				Disk:   fromMBPtr(sidecar.DiskMB),
+				CPU:    fromCPUWeightPtr(sidecar.CPUWeight),
+			},
+			HealthChecks: healthChecksFromV1Ptr(sidecar.Health),
+			Start:        sidecar.Start,
+		})
+	}
+
+	return out
+}
+
+func instanceRestartsToV1(in []InstanceRestartRequest) []v1.InstanceRestartRequest {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]v1.InstanceRestartRequest, 0, len(in))
+	for _, request := range in {
+		out = append(out, v1.InstanceRestartRequest(request))
+	}
+
+	return out
+}
+
+func instanceRestartsFromV1(in []v1.InstanceRestartRequest) []InstanceRestartRequest {
+	if in == nil {
+		return nil
+	}
+
+	out := make([]InstanceRestartRequest, 0, len(in))
+	for _, request := range in {
+		out = append(out, InstanceRestartRequest(request))
+	}
+
+	return out
+}
+
+func lrpStatusToV1(in LRPStatus) v1.LRPStatus {
+	out := v1.LRPStatus{
+		Replicas:                   in.Replicas,
+		Selector:                   in.Selector,
+		ObservedGeneration:         in.ObservedGeneration,
+		RunningInstances:           in.RunningInstances,
+		StartingInstances:          in.StartingInstances,
+		CrashedInstances:           in.CrashedInstances,
+		ActiveVersion:              in.ActiveVersion,
+		PreviousVersion:            in.PreviousVersion,
+		RetiringVersions:           in.RetiringVersions,
+		FailedVersion:              in.FailedVersion,
+		VersionTransitionStartTime: in.VersionTransitionStartTime,
+		Conditions:                 in.Conditions,
+	}
+
+	if in.Instances != nil {
+		out.Instances = make([]v1.InstanceStatus, 0, len(in.Instances))
+		for _, instance := range in.Instances {
+			out.Instances = append(out.Instances, v1.InstanceStatus(instance))
+		}
+	}
+
+	if in.HandledInstanceRestarts != nil {
+		out.HandledInstanceRestarts = make([]v1.InstanceRestartStatus, 0, len(in.HandledInstanceRestarts))
+		for _, restart := range in.HandledInstanceRestarts {
+			out.HandledInstanceRestarts = append(out.HandledInstanceRestarts, v1.InstanceRestartStatus(restart))
+		}
+	}
+
+	return out
+}
+
+func lrpStatusFromV1(in v1.LRPStatus) LRPStatus {
+	out := LRPStatus{
+		Replicas:                   in.Replicas,
+		Selector:                   in.Selector,
+		ObservedGeneration:         in.ObservedGeneration,
+		RunningInstances:           in.RunningInstances,
+		StartingInstances:          in.StartingInstances,
+		CrashedInstances:           in.CrashedInstances,
+		ActiveVersion:              in.ActiveVersion,
+		PreviousVersion:            in.PreviousVersion,
+		RetiringVersions:           in.RetiringVersions,
+		FailedVersion:              in.FailedVersion,
+		VersionTransitionStartTime: in.VersionTransitionStartTime,
+		Conditions:                 in.Conditions,
+	}
+
+	if in.Instances != nil {
+		out.Instances = make([]InstanceStatus, 0, len(in.Instances))
+		for _, instance := range in.Instances {
+			out.Instances = append(out.Instances, InstanceStatus(instance))
+		}
+	}
+
+	if in.HandledInstanceRestarts != nil {
+		out.HandledInstanceRestarts = make([]InstanceRestartStatus, 0, len(in.HandledInstanceRestarts))
+		for _, restart := range in.HandledInstanceRestarts {
+			out.HandledInstanceRestarts = append(out.HandledInstanceRestarts, InstanceRestartStatus(restart))
+		}
+	}
+
+	return out
+}
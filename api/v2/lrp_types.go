@@ -0,0 +1,324 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// +kubebuilder:validation:Optional
+package v2
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LRPSpec defines the desired state of LRP
+type LRPSpec struct {
+	// +kubebuilder:validation:Required
+	GUID        string `json:"guid"`
+	Version     string `json:"version"`
+	ProcessType string `json:"processType"`
+	AppName     string `json:"appName"`
+	AppGUID     string `json:"appGUID"`
+	OrgName     string `json:"orgName"`
+	OrgGUID     string `json:"orgGUID"`
+	SpaceName   string `json:"spaceName"`
+	SpaceGUID   string `json:"spaceGUID"`
+	// +kubebuilder:validation:Required
+	Image           string            `json:"image"`
+	Command         []string          `json:"command,omitempty"`
+	Sidecars        []Sidecar         `json:"sidecars,omitempty"`
+	PrivateRegistry *PrivateRegistry  `json:"privateRegistry,omitempty"`
+	Env             map[string]string `json:"env,omitempty"`
+	// SecretEnv sets environment variables from keys of Secrets in the
+	// namespace of the LRP
+	SecretEnv []SecretEnvVar `json:"secretEnv,omitempty"`
+	// EnvInSecret moves Env into a Secret owned by the LRP, which the
+	// instances reference instead of carrying the values in their pod
+	// template. Changing the Secret restarts the instances.
+	EnvInSecret  bool         `json:"envInSecret,omitempty"`
+	HealthChecks HealthChecks `json:"healthChecks,omitempty"`
+	Ports        []Port       `json:"ports,omitempty"`
+	// Routes expose ports of the LRP outside the cluster, through an Ingress
+	Routes []Route `json:"routes,omitempty"`
+	// +kubebuilder:default:=1
+	// +kubebuilder:validation:Minimum:=0
+	Instances              int               `json:"instances"`
+	Resources              Resources         `json:"resources,omitempty"`
+	VolumeMounts           []VolumeMount     `json:"volumeMounts,omitempty"`
+	LastUpdated            string            `json:"lastUpdated"`
+	UserDefinedAnnotations map[string]string `json:"userDefinedAnnotations,omitempty"`
+	// InstanceRestarts asks for single instances to be restarted. Each
+	// request is run once, even if it stays in the spec.
+	// +listType=map
+	// +listMapKey=id
+	InstanceRestarts []InstanceRestartRequest `json:"instanceRestarts,omitempty"`
+	// Autoscaling lets a HorizontalPodAutoscaler set the number of
+	// instances. When it is set, instances should not be changed otherwise.
+	Autoscaling *Autoscaling `json:"autoscaling,omitempty"`
+}
+
+// Resources are what a workload gets of each resource. Memory and disk are
+// limits, CPU is a request.
+type Resources struct {
+	Memory resource.Quantity `json:"memory,omitempty"`
+	Disk   resource.Quantity `json:"disk,omitempty"`
+	CPU    resource.Quantity `json:"cpu,omitempty"`
+}
+
+// Port is a port the app listens on
+type Port struct {
+	// Name is the name of the port in the LRP Service
+	Name string `json:"name,omitempty"`
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Maximum:=65535
+	ContainerPort int32 `json:"containerPort"`
+	// Protocol defaults to TCP
+	// +kubebuilder:validation:Enum=TCP;UDP;SCTP
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+}
+
+type Route struct {
+	Hostname string `json:"hostname"`
+	Port     int32  `json:"port"`
+}
+
+const (
+	HealthCheckTypeHTTP    = "http"
+	HealthCheckTypePort    = "port"
+	HealthCheckTypeProcess = "process"
+)
+
+// HealthChecks decide when instances are restarted and when they get
+// traffic
+type HealthChecks struct {
+	// Liveness restarts instances that fail it. Without it, instances are
+	// only restarted when the app process exits.
+	Liveness *Probe `json:"liveness,omitempty"`
+	// Readiness decides whether an instance gets traffic. Without it, the
+	// liveness check is used.
+	Readiness *Probe `json:"readiness,omitempty"`
+	// StartTimeout is how long the app may take to pass its first liveness
+	// check
+	StartTimeout *metav1.Duration `json:"startTimeout,omitempty"`
+}
+
+type Probe struct {
+	// A process check only relies on the app process staying alive
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=http;port;process
+	Type     string `json:"type"`
+	Port     int32  `json:"port,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	// +kubebuilder:validation:Minimum:=1
+	InvocationTimeoutSeconds *int32 `json:"invocationTimeoutSeconds,omitempty"`
+	// +kubebuilder:validation:Minimum:=1
+	PeriodSeconds *int32 `json:"periodSeconds,omitempty"`
+	// +kubebuilder:validation:Minimum:=1
+	FailureThreshold *int32 `json:"failureThreshold,omitempty"`
+}
+
+const (
+	SidecarStartBeforeApp = "BeforeApp"
+	SidecarStartAfterApp  = "AfterApp"
+)
+
+type Sidecar struct {
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// +kubebuilder:validation:Required
+	Command []string          `json:"command"`
+	Env     map[string]string `json:"env,omitempty"`
+	// Image defaults to the app image
+	Image     string           `json:"image,omitempty"`
+	Ports     []Port           `json:"ports,omitempty"`
+	Resources SidecarResources `json:"resources,omitempty"`
+	// HealthChecks of a sidecar are separate from those of the app
+	HealthChecks *HealthChecks `json:"healthChecks,omitempty"`
+	// Start says whether the sidecar container is placed before or after the
+	// app container. The kubelet starts containers in that order, but does
+	// not wait for one to be running or ready before starting the next, so
+	// the order is best-effort. Apps that depend on a sidecar, such as a
+	// proxy, still have to retry until it is up.
+	// +kubebuilder:validation:Enum=BeforeApp;AfterApp
+	// +kubebuilder:default:=AfterApp
+	Start string `json:"start,omitempty"`
+}
+
+// SidecarResources are the resources of a sidecar. CPU and disk default to
+// those of the app.
+type SidecarResources struct {
+	Memory resource.Quantity  `json:"memory,omitempty"`
+	Disk   *resource.Quantity `json:"disk,omitempty"`
+	CPU    *resource.Quantity `json:"cpu,omitempty"`
+}
+
+// PrivateRegistry holds the credentials for pulling the image, either
+// inline or from a Secret in the same namespace. The Secret may be of type
+// kubernetes.io/dockerconfigjson, in which case it is used as the pull
+// secret, or kubernetes.io/basic-auth.
+type PrivateRegistry struct {
+	Username   string `json:"username,omitempty"`
+	Password   string `json:"password,omitempty"`
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// SecretEnvVar is an environment variable whose value is a key of a Secret
+type SecretEnvVar struct {
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// +kubebuilder:validation:Required
+	SecretName string `json:"secretName"`
+	// +kubebuilder:validation:Required
+	Key string `json:"key"`
+}
+
+// VolumeMount mounts a volume into the app container. Exactly one of
+// ClaimName, ConfigMap, Secret and EmptyDir must be set.
+type VolumeMount struct {
+	// +kubebuilder:validation:Required
+	MountPath string `json:"mountPath"`
+	ReadOnly  bool   `json:"readOnly,omitempty"`
+	SubPath   string `json:"subPath,omitempty"`
+	// ClaimName mounts an existing PersistentVolumeClaim
+	ClaimName string                 `json:"claimName,omitempty"`
+	ConfigMap *ConfigMapVolumeSource `json:"configMap,omitempty"`
+	Secret    *SecretVolumeSource    `json:"secret,omitempty"`
+	EmptyDir  *EmptyDirVolumeSource  `json:"emptyDir,omitempty"`
+}
+
+type ConfigMapVolumeSource struct {
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+}
+
+type SecretVolumeSource struct {
+	// +kubebuilder:validation:Required
+	SecretName string `json:"secretName"`
+}
+
+type EmptyDirVolumeSource struct {
+	// +kubebuilder:validation:Enum="";Memory
+	Medium    string             `json:"medium,omitempty"`
+	SizeLimit *resource.Quantity `json:"sizeLimit,omitempty"`
+}
+
+type Autoscaling struct {
+	// +kubebuilder:validation:Minimum:=1
+	MinInstances *int32 `json:"minInstances,omitempty"`
+	// +kubebuilder:validation:Minimum:=1
+	MaxInstances int32 `json:"maxInstances"`
+	// +kubebuilder:validation:Minimum:=1
+	TargetCPUUtilizationPercentage *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+	// +kubebuilder:validation:Minimum:=1
+	TargetMemoryUtilizationPercentage *int32 `json:"targetMemoryUtilizationPercentage,omitempty"`
+}
+
+type InstanceRestartRequest struct {
+	// ID identifies the request and must be unique, e.g. a nonce
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength:=1
+	ID string `json:"id"`
+	// +kubebuilder:validation:Minimum:=0
+	Index int `json:"index"`
+}
+
+const (
+	LRPConditionReady                 = "Ready"
+	LRPConditionProgressing           = "Progressing"
+	LRPConditionDegraded              = "Degraded"
+	LRPConditionImagePullFailed       = "ImagePullFailed"
+	LRPConditionInsufficientResources = "InsufficientResources"
+	LRPConditionRolledBack            = "RolledBack"
+)
+
+// InstanceStatus describes a single instance (pod) of an LRP
+type InstanceStatus struct {
+	Index int `json:"index"`
+	// +kubebuilder:validation:Enum=RUNNING;CLAIMED;CRASHED;UNCLAIMED;UNKNOWN
+	State           string       `json:"state"`
+	Since           *metav1.Time `json:"since,omitempty"`
+	PlacementError  string       `json:"placementError,omitempty"`
+	RestartCount    int32        `json:"restartCount"`
+	LastCrashReason string       `json:"lastCrashReason,omitempty"`
+}
+
+type InstanceRestartStatus struct {
+	ID          string      `json:"id"`
+	Index       int         `json:"index"`
+	HandledTime metav1.Time `json:"handledTime"`
+	Error       string      `json:"error,omitempty"`
+}
+
+// LRPStatus defines the observed state of LRP
+type LRPStatus struct {
+	Replicas int32 `json:"replicas"`
+	// Selector is the label selector of the LRP instances, used by the
+	// scale subresource
+	Selector           string `json:"selector,omitempty"`
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+	RunningInstances   int32  `json:"runningInstances"`
+	StartingInstances  int32  `json:"startingInstances"`
+	CrashedInstances   int32  `json:"crashedInstances"`
+	// +listType=map
+	// +listMapKey=index
+	Instances []InstanceStatus `json:"instances,omitempty"`
+	// ActiveVersion is the version serving the app. While a new version is
+	// coming up it is still the previous one.
+	ActiveVersion string `json:"activeVersion,omitempty"`
+	// PreviousVersion is the version that was active before ActiveVersion
+	PreviousVersion string `json:"previousVersion,omitempty"`
+	// RetiringVersions are the versions whose StatefulSets are being stopped
+	RetiringVersions []string `json:"retiringVersions,omitempty"`
+	// FailedVersion is a version that did not become ready in time and was
+	// rolled back. It is not desired again until the spec version changes.
+	FailedVersion string `json:"failedVersion,omitempty"`
+	// VersionTransitionStartTime is when the current version transition began
+	VersionTransitionStartTime *metav1.Time `json:"versionTransitionStartTime,omitempty"`
+	// HandledInstanceRestarts are the instance restart requests in the spec
+	// that have already been run
+	// +listType=map
+	// +listMapKey=id
+	HandledInstanceRestarts []InstanceRestartStatus `json:"handledInstanceRestarts,omitempty"`
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:subresource:scale:specpath=.spec.instances,statuspath=.status.replicas,selectorpath=.status.selector
+
+// LRP is the Schema for the lrps API
+type LRP struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   LRPSpec   `json:"spec,omitempty"`
+	Status LRPStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// LRPList contains a list of LRP
+type LRPList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LRP `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&LRP{}, &LRPList{})
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"fmt"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var lrplog = logf.Log.WithName("lrp-v2-resource")
+
+// SetupWebhookWithManager registers the conversion and validation webhooks.
+// Defaulting and the validation of the spec itself are done by the v1
+// webhooks, which the API server also calls for v2 requests. The v2 webhook
+// only rejects what cannot be converted to v1.
+func (r *LRP) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-eirini-cloudfoundry-org-v2-lrp,mutating=false,failurePolicy=fail,sideEffects=None,groups=eirini.cloudfoundry.org,resources=lrps,verbs=create;update,versions=v2,name=vlrpv2.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Validator = &LRP{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *LRP) ValidateCreate() error {
+	lrplog.Info("validate create", "name", r.Name)
+
+	return r.validate(nil)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *LRP) ValidateUpdate(old runtime.Object) error {
+	lrplog.Info("validate update", "name", r.Name)
+
+	oldLRP, ok := old.(*LRP)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected an LRP but got a %T", old))
+	}
+
+	return r.validate(oldLRP)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *LRP) ValidateDelete() error {
+	return nil
+}
+
+// validate rejects values v1 cannot represent. Like the v1 webhook, it lets
+// through LRPs that are being deleted and updates that leave the spec alone.
+func (r *LRP) validate(old *LRP) error {
+	if old != nil && (r.DeletionTimestamp != nil || apiequality.Semantic.DeepEqual(r.Spec, old.Spec)) {
+		return nil
+	}
+
+	specPath := field.NewPath("spec")
+
+	allErrs := validateResources(specPath.Child("resources"), r.Spec.Resources)
+	allErrs = append(allErrs, validatePorts(specPath.Child("ports"), r.Spec.Ports)...)
+	allErrs = append(allErrs, validateHealthChecks(specPath.Child("healthChecks"), r.Spec.HealthChecks)...)
+
+	for i, sidecar := range r.Spec.Sidecars {
+		sidecarPath := specPath.Child("sidecars").Index(i)
+
+		allErrs = append(allErrs, validateSidecarResources(sidecarPath.Child("resources"), sidecar.Resources)...)
+		allErrs = append(allErrs, validatePorts(sidecarPath.Child("ports"), sidecar.Ports)...)
+
+		if sidecar.HealthChecks != nil {
+			allErrs = append(allErrs, validateHealthChecks(sidecarPath.Child("healthChecks"), *sidecar.HealthChecks)...)
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(GroupVersion.WithKind("LRP").GroupKind(), r.Name, allErrs)
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("LRP Webhook", func() {
+	var lrp *LRP
+
+	BeforeEach(func() {
+		lrp = &LRP{
+			ObjectMeta: metav1.ObjectMeta{Name: "lrp"},
+			Spec: LRPSpec{
+				GUID:  "guid",
+				Image: "eirini/dorini",
+				Ports: []Port{{Name: "http", ContainerPort: 8080, Protocol: corev1.ProtocolTCP}},
+				Resources: Resources{
+					Memory: resource.MustParse("256M"),
+					Disk:   resource.MustParse("1G"),
+					CPU:    resource.MustParse("40m"),
+				},
+				HealthChecks: HealthChecks{StartTimeout: &metav1.Duration{Duration: time.Minute}},
+				Sidecars: []Sidecar{{
+					Name:    "proxy",
+					Command: []string{"proxy"},
+					Resources: SidecarResources{
+						Memory: resource.MustParse("64M"),
+					},
+				}},
+			},
+		}
+	})
+
+	It("accepts an lrp v1 can represent", func() {
+		Expect(lrp.ValidateCreate()).To(Succeed())
+	})
+
+	When("the memory is not a whole number of megabytes", func() {
+		BeforeEach(func() {
+			lrp.Spec.Resources.Memory = resource.MustParse("256Mi")
+		})
+
+		It("rejects the lrp", func() {
+			Expect(lrp.ValidateCreate()).To(MatchError(ContainSubstring("spec.resources.memory")))
+		})
+	})
+
+	When("the cpu is more than v1 cpu weights allow", func() {
+		BeforeEach(func() {
+			lrp.Spec.Resources.CPU = resource.MustParse("1")
+		})
+
+		It("rejects the lrp", func() {
+			Expect(lrp.ValidateCreate()).To(MatchError(ContainSubstring("spec.resources.cpu")))
+		})
+	})
+
+	When("the cpu is not a whole number of millicores", func() {
+		BeforeEach(func() {
+			lrp.Spec.Resources.CPU = resource.MustParse("100u")
+		})
+
+		It("rejects the lrp", func() {
+			Expect(lrp.ValidateCreate()).To(MatchError(ContainSubstring("spec.resources.cpu")))
+		})
+	})
+
+	When("a port is not TCP", func() {
+		BeforeEach(func() {
+			lrp.Spec.Ports[0].Protocol = corev1.ProtocolUDP
+		})
+
+		It("rejects the lrp", func() {
+			Expect(lrp.ValidateCreate()).To(MatchError(ContainSubstring("spec.ports[0].protocol")))
+		})
+	})
+
+	When("the start timeout is not a whole number of milliseconds", func() {
+		BeforeEach(func() {
+			lrp.Spec.HealthChecks.StartTimeout.Duration = 1500 * time.Microsecond
+		})
+
+		It("rejects the lrp", func() {
+			Expect(lrp.ValidateCreate()).To(MatchError(ContainSubstring("spec.healthChecks.startTimeout")))
+		})
+	})
+
+	When("a sidecar sets values v1 cannot represent", func() {
+		BeforeEach(func() {
+			cpu := resource.MustParse("300m")
+			lrp.Spec.Sidecars[0].Resources.CPU = &cpu
+			lrp.Spec.Sidecars[0].Ports = []Port{{ContainerPort: 9090, Protocol: corev1.ProtocolSCTP}}
+		})
+
+		It("rejects the lrp", func() {
+			err := lrp.ValidateCreate()
+			Expect(err).To(MatchError(ContainSubstring("spec.sidecars[0].resources.cpu")))
+			Expect(err).To(MatchError(ContainSubstring("spec.sidecars[0].ports[0].protocol")))
+		})
+	})
+
+	When("an lrp that v1 cannot represent is updated", func() {
+		var old *LRP
+
+		BeforeEach(func() {
+			lrp.Spec.Resources.CPU = resource.MustParse("1")
+			old = lrp.DeepCopy()
+		})
+
+		It("accepts updates that leave the spec alone", func() {
+			lrp.Finalizers = []string{"eirini.cloudfoundry.org/test"}
+			Expect(lrp.ValidateUpdate(old)).To(Succeed())
+		})
+
+		It("accepts the lrp being deleted", func() {
+			lrp.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			lrp.Spec.Instances = 0
+			Expect(lrp.ValidateUpdate(old)).To(Succeed())
+		})
+
+		It("rejects other changes to the spec", func() {
+			lrp.Spec.Instances = 3
+			Expect(lrp.ValidateUpdate(old)).To(MatchError(ContainSubstring("spec.resources.cpu")))
+		})
+	})
+})
@@ -0,0 +1,166 @@
+package v2
+
+import (
+	"fmt"
+
+	v1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// ConvertTo converts this Task to the hub version (v1)
+func (src *Task) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1.Task)
+	if !ok {
+		return fmt.Errorf("unsupported hub %T", dstRaw)
+	}
+
+	in := src.DeepCopy()
+
+	dst.ObjectMeta = in.ObjectMeta
+	dst.Spec = taskSpecToV1(in.Spec)
+	dst.Status = taskStatusToV1(in.Status)
+
+	deleteConversionData(&dst.ObjectMeta)
+
+	if apiequality.Semantic.DeepEqual(taskSpecFromV1(*dst.Spec.DeepCopy()), src.Spec) {
+		return nil
+	}
+
+	return storeConversionData(&dst.ObjectMeta, src.Spec)
+}
+
+// ConvertFrom converts from the hub version (v1) to this version
+func (dst *Task) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1.Task)
+	if !ok {
+		return fmt.Errorf("unsupported hub %T", srcRaw)
+	}
+
+	in := src.DeepCopy()
+
+	dst.ObjectMeta = in.ObjectMeta
+	dst.Spec = taskSpecFromV1(in.Spec)
+	dst.Status = taskStatusFromV1(in.Status)
+
+	stored := TaskSpec{}
+
+	restored, err := restoreConversionData(&dst.ObjectMeta, &stored)
+	if err != nil {
+		return err
+	}
+
+	if restored && apiequality.Semantic.DeepEqual(taskSpecToV1(*stored.DeepCopy()), src.Spec) {
+		dst.Spec = stored
+	}
+
+	return nil
+}
+
+func taskSpecToV1(in TaskSpec) v1.TaskSpec {
+	return v1.TaskSpec{
+		GUID:                    in.GUID,
+		Name:                    in.Name,
+		Image:                   in.Image,
+		CompletionCallback:      in.CompletionCallback,
+		PrivateRegistry:         privateRegistryToV1(in.PrivateRegistry),
+		Env:                     in.Env,
+		SecretEnv:               secretEnvToV1(in.SecretEnv),
+		EnvInSecret:             in.EnvInSecret,
+		Command:                 in.Command,
+		AppName:                 in.AppName,
+		AppGUID:                 in.AppGUID,
+		OrgName:                 in.OrgName,
+		OrgGUID:                 in.OrgGUID,
+		SpaceName:               in.SpaceName,
+		SpaceGUID:               in.SpaceGUID,
+		MemoryMB:                toMB(in.Resources.Memory),
+		DiskMB:                  toMB(in.Resources.Disk),
+		CPUWeight:               toCPUWeight(in.Resources.CPU),
+		VolumeMounts:            volumeMountsToV1(in.VolumeMounts),
+		TTLSecondsAfterFinished: in.TTLSecondsAfterFinished,
+		TimeoutSeconds:          in.TimeoutSeconds,
+		MaxRetries:              in.MaxRetries,
+		Cancelled:               in.Cancelled,
+	}
+}
+
+func taskSpecFromV1(in v1.TaskSpec) TaskSpec {
+	return TaskSpec{
+		GUID:               in.GUID,
+		Name:               in.Name,
+		Image:              in.Image,
+		CompletionCallback: in.CompletionCallback,
+		PrivateRegistry:    privateRegistryFromV1(in.PrivateRegistry),
+		Env:                in.Env,
+		SecretEnv:          secretEnvFromV1(in.SecretEnv),
+		EnvInSecret:        in.EnvInSecret,
+		Command:            in.Command,
+		AppName:            in.AppName,
+		AppGUID:            in.AppGUID,
+		OrgName:            in.OrgName,
+		OrgGUID:            in.OrgGUID,
+		SpaceName:          in.SpaceName,
+		SpaceGUID:          in.SpaceGUID,
+		Resources: Resources{
+			Memory: fromMB(in.MemoryMB),
+			Disk:   fromMB(in.DiskMB),
+			CPU:    fromCPUWeight(in.CPUWeight),
+		},
+		VolumeMounts:            volumeMountsFromV1(in.VolumeMounts),
+		TTLSecondsAfterFinished: in.TTLSecondsAfterFinished,
+		TimeoutSeconds:          in.TimeoutSeconds,
+		MaxRetries:              in.MaxRetries,
+		Cancelled:               in.Cancelled,
+	}
+}
+
+func taskStatusToV1(in TaskStatus) v1.TaskStatus {
+	out := v1.TaskStatus{
+		StartTime:          in.StartTime,
+		EndTime:            in.EndTime,
+		ExecutionStatus:    v1.ExecutionStatus(in.ExecutionStatus),
+		ExitCode:           in.ExitCode,
+		TerminationReason:  in.TerminationReason,
+		TerminationMessage: in.TerminationMessage,
+		FailureReason:      in.FailureReason,
+		ObservedGeneration: in.ObservedGeneration,
+		Conditions:         in.Conditions,
+	}
+
+	if callback := in.CompletionCallback; callback != nil {
+		out.CompletionCallback = &v1.CompletionCallbackStatus{
+			State:           v1.CallbackDeliveryState(callback.State),
+			Attempts:        callback.Attempts,
+			LastAttemptTime: callback.LastAttemptTime,
+			LastError:       callback.LastError,
+		}
+	}
+
+	return out
+}
+
+func taskStatusFromV1(in v1.TaskStatus) TaskStatus {
+	out := TaskStatus{
+		StartTime:          in.StartTime,
+		EndTime:            in.EndTime,
+		ExecutionStatus:    ExecutionStatus(in.ExecutionStatus),
+		ExitCode:           in.ExitCode,
+		TerminationReason:  in.TerminationReason,
+		TerminationMessage: in.TerminationMessage,
+		FailureReason:      in.FailureReason,
+		ObservedGeneration: in.ObservedGeneration,
+		Conditions:         in.Conditions,
+	}
+
+	if callback := in.CompletionCallback; callback != nil {
+		out.CompletionCallback = &CompletionCallbackStatus{
+			State:           CallbackDeliveryState(callback.State),
+			Attempts:        callback.Attempts,
+			LastAttemptTime: callback.LastAttemptTime,
+			LastError:       callback.LastError,
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// +kubebuilder:validation:Optional
+package v2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TaskSpec defines the desired state of Task
+type TaskSpec struct {
+	// +kubebuilder:validation:Required
+	GUID string `json:"guid"`
+	Name string `json:"name"`
+	// +kubebuilder:validation:Required
+	Image string `json:"image"`
+	// CompletionCallback is the URL the controller POSTs to once the task
+	// has completed
+	CompletionCallback string            `json:"completionCallback,omitempty"`
+	PrivateRegistry    *PrivateRegistry  `json:"privateRegistry,omitempty"`
+	Env                map[string]string `json:"env,omitempty"`
+	// SecretEnv sets environment variables from keys of Secrets in the
+	// namespace of the task
+	SecretEnv []SecretEnvVar `json:"secretEnv,omitempty"`
+	// EnvInSecret moves Env into a Secret owned by the task, which the task
+	// references instead of carrying the values in its pod template
+	EnvInSecret bool `json:"envInSecret,omitempty"`
+	// +kubebuilder:validation:Required
+	Command      []string      `json:"command,omitempty"`
+	AppName      string        `json:"appName"`
+	AppGUID      string        `json:"appGUID"`
+	OrgName      string        `json:"orgName"`
+	OrgGUID      string        `json:"orgGUID"`
+	SpaceName    string        `json:"spaceName"`
+	SpaceGUID    string        `json:"spaceGUID"`
+	Resources    Resources     `json:"resources,omitempty"`
+	VolumeMounts []VolumeMount `json:"volumeMounts,omitempty"`
+	// TTLSecondsAfterFinished overrides how long the controller keeps the
+	// task around after it has succeeded or failed
+	// +kubebuilder:validation:Minimum:=0
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+	// TimeoutSeconds is how long the task may run before it is stopped and
+	// marked as failed
+	// +kubebuilder:validation:Minimum:=1
+	TimeoutSeconds *int64 `json:"timeoutSeconds,omitempty"`
+	// MaxRetries is how many times a failed task is retried
+	// +kubebuilder:validation:Minimum:=0
+	MaxRetries *int32 `json:"maxRetries,omitempty"`
+	// Cancelled stops the task by deleting its job, while keeping the task
+	// itself around to record the outcome
+	Cancelled bool `json:"cancelled,omitempty"`
+}
+
+type ExecutionStatus string
+
+const (
+	TaskStarting  ExecutionStatus = "starting"
+	TaskRunning   ExecutionStatus = "running"
+	TaskSucceeded ExecutionStatus = "succeeded"
+	TaskFailed    ExecutionStatus = "failed"
+	TaskCancelled ExecutionStatus = "cancelled"
+)
+
+const (
+	TaskConditionScheduled = "Scheduled"
+	TaskConditionRunning   = "Running"
+	TaskConditionComplete  = "Complete"
+	TaskConditionFailed    = "Failed"
+)
+
+type CallbackDeliveryState string
+
+const (
+	CallbackPending   CallbackDeliveryState = "pending"
+	CallbackDelivered CallbackDeliveryState = "delivered"
+	CallbackFailed    CallbackDeliveryState = "failed"
+)
+
+type CompletionCallbackStatus struct {
+	// +kubebuilder:validation:Enum=pending;delivered;failed
+	State           CallbackDeliveryState `json:"state"`
+	Attempts        int32                 `json:"attempts"`
+	LastAttemptTime *metav1.Time          `json:"lastAttemptTime,omitempty"`
+	LastError       string                `json:"lastError,omitempty"`
+}
+
+// TaskStatus defines the observed state of Task
+type TaskStatus struct {
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	EndTime   *metav1.Time `json:"endTime,omitempty"`
+	// +kubebuilder:validation:Enum=starting;running;succeeded;failed;cancelled
+	// +kubebuilder:default=starting
+	ExecutionStatus ExecutionStatus `json:"executionStatus"`
+	// ExitCode, TerminationReason and TerminationMessage are read from the
+	// task container once it has terminated
+	ExitCode           *int32 `json:"exitCode,omitempty"`
+	TerminationReason  string `json:"terminationReason,omitempty"`
+	TerminationMessage string `json:"terminationMessage,omitempty"`
+	// FailureReason explains why the task failed, or why it cannot start
+	FailureReason      string                    `json:"failureReason,omitempty"`
+	CompletionCallback *CompletionCallbackStatus `json:"completionCallback,omitempty"`
+	ObservedGeneration int64                     `json:"observedGeneration,omitempty"`
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// Task is the Schema for the tasks API
+type Task struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TaskSpec   `json:"spec,omitempty"`
+	Status TaskStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// TaskList contains a list of Task
+type TaskList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Task `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Task{}, &TaskList{})
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Task Webhook", func() {
+	var task *Task
+
+	BeforeEach(func() {
+		task = &Task{
+			ObjectMeta: metav1.ObjectMeta{Name: "task"},
+			Spec: TaskSpec{
+				GUID:      "guid",
+				Image:     "eirini/busybox",
+				Command:   []string{"sh", "-c", "echo hi"},
+				Resources: Resources{Memory: resource.MustParse("256M")},
+			},
+		}
+	})
+
+	It("accepts a task v1 can represent", func() {
+		Expect(task.ValidateCreate()).To(Succeed())
+	})
+
+	When("the disk is not a whole number of megabytes", func() {
+		BeforeEach(func() {
+			task.Spec.Resources.Disk = resource.MustParse("1Gi")
+		})
+
+		It("rejects the task", func() {
+			Expect(task.ValidateCreate()).To(MatchError(ContainSubstring("spec.resources.disk")))
+		})
+	})
+
+	When("the cpu is more than v1 cpu weights allow", func() {
+		BeforeEach(func() {
+			task.Spec.Resources.CPU = resource.MustParse("256m")
+		})
+
+		It("rejects the task", func() {
+			Expect(task.ValidateCreate()).To(MatchError(ContainSubstring("spec.resources.cpu")))
+		})
+	})
+})
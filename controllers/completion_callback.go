@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/eirini"
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/util"
+	"code.cloudfoundry.org/lager"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	TaskCompletionCallbackFinalizer = "eirini.cloudfoundry.org/completion-callback"
+
+	callbackInitialBackoff = time.Second
+	callbackMaxBackoff     = 5 * time.Minute
+
+	taskFailedReason    = "task failed"
+	taskCancelledReason = "task was cancelled"
+	taskDeletedReason   = "task was deleted before it completed"
+)
+
+type JSONClient interface {
+	Post(ctx context.Context, url string, data interface{}) error
+}
+
+type TaskCompletedRequest struct {
+	TaskGUID      string `json:"task_guid"`
+	Failed        bool   `json:"failed"`
+	FailureReason string `json:"failure_reason"`
+}
+
+// CreateCompletionCallbackClient returns a client that makes a single attempt
+// per call. Retries are driven by the TaskReconciler, which records every
+// attempt in the Task status so that they survive controller restarts. The
+// CC certificates are only loaded once a callback is delivered, so that
+// deployments that never use callbacks do not need them.
+func CreateCompletionCallbackClient(ccTLSDisabled bool, ccCertDir string) JSONClient {
+	return &lazyJSONClient{
+		newClient: func() (JSONClient, error) {
+			httpClient := &http.Client{}
+
+			if !ccTLSDisabled {
+				var err error
+
+				httpClient, err = util.CreateTLSHTTPClient([]util.CertPaths{
+					{
+						Crt: filepath.Join(ccCertDir, eirini.TLSSecretCert),
+						Key: filepath.Join(ccCertDir, eirini.TLSSecretKey),
+						Ca:  filepath.Join(ccCertDir, eirini.TLSSecretCA),
+					},
+				})
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to create cc tls client")
+				}
+			}
+
+			return util.NewRetryableJSONClientWithConfig(httpClient, 0, 0, ioutil.Discard), nil
+		},
+	}
+}
+
+// lazyJSONClient creates its client on first use, and keeps trying on later
+// calls until that succeeds
+type lazyJSONClient struct {
+	newClient func() (JSONClient, error)
+
+	mu     sync.Mutex
+	client JSONClient
+}
+
+func (c *lazyJSONClient) Post(ctx context.Context, url string, data interface{}) error {
+	c.mu.Lock()
+	if c.client == nil {
+		client, err := c.newClient()
+		if err != nil {
+			c.mu.Unlock()
+
+			return err
+		}
+
+		c.client = client
+	}
+	client := c.client
+	c.mu.Unlock()
+
+	return client.Post(ctx, url, data)
+}
+
+func (r *TaskReconciler) deliverCompletionCallback(ctx context.Context, logger lager.Logger, task *eiriniv1.Task) (ctrl.Result, error) {
+	callbackStatus := eiriniv1.CompletionCallbackStatus{State: eiriniv1.CallbackPending}
+	if task.Status.CompletionCallback != nil {
+		callbackStatus = *task.Status.CompletionCallback.DeepCopy()
+	}
+
+	if callbackStatus.LastAttemptTime != nil {
+		nextAttempt := callbackStatus.LastAttemptTime.Add(callbackBackoff(callbackStatus.Attempts))
+		if waitFor := time.Until(nextAttempt); waitFor > 0 {
+			return ctrl.Result{RequeueAfter: waitFor}, nil
+		}
+	}
+
+	err := r.CallbackClient.Post(ctx, task.Spec.CompletionCallback, toTaskCompletedRequest(task))
+	now := metav1.Now()
+	callbackStatus.Attempts++
+	callbackStatus.LastAttemptTime = &now
+	callbackStatus.LastError = ""
+	callbackStatus.State = eiriniv1.CallbackDelivered
+
+	if err != nil {
+		logger.Error("failed-to-deliver-completion-callback", err, lager.Data{"attempts": callbackStatus.Attempts})
+
+		callbackStatus.LastError = err.Error()
+		callbackStatus.State = eiriniv1.CallbackPending
+
+		if int(callbackStatus.Attempts) > r.CompletionCallbackRetryLimit {
+			callbackStatus.State = eiriniv1.CallbackFailed
+		}
+	}
+
+	newStatus := task.Status.DeepCopy()
+	newStatus.CompletionCallback = &callbackStatus
+
+	if err = r.UpdateTaskStatus(ctx, task, *newStatus); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to record completion callback delivery")
+	}
+
+	if callbackStatus.State == eiriniv1.CallbackPending {
+		return ctrl.Result{RequeueAfter: callbackBackoff(callbackStatus.Attempts)}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *TaskReconciler) addCallbackFinalizer(ctx context.Context, task *eiriniv1.Task) error {
+	if !callbackPending(task) || controllerutil.ContainsFinalizer(task, TaskCompletionCallbackFinalizer) {
+		return nil
+	}
+
+	controllerutil.AddFinalizer(task, TaskCompletionCallbackFinalizer)
+
+	return errors.Wrap(r.Update(ctx, task), "failed to add completion callback finalizer")
+}
+
+func (r *TaskReconciler) removeCallbackFinalizer(ctx context.Context, task *eiriniv1.Task) error {
+	if !controllerutil.ContainsFinalizer(task, TaskCompletionCallbackFinalizer) {
+		return nil
+	}
+
+	controllerutil.RemoveFinalizer(task, TaskCompletionCallbackFinalizer)
+
+	return errors.Wrap(r.Update(ctx, task), "failed to remove completion callback finalizer")
+}
+
+func callbackPending(task *eiriniv1.Task) bool {
+	if task.Spec.CompletionCallback == "" {
+		return false
+	}
+
+	return task.Status.CompletionCallback == nil ||
+		task.Status.CompletionCallback.State == eiriniv1.CallbackPending
+}
+
+func callbackBackoff(attempts int32) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+
+	backoff := float64(callbackInitialBackoff) * math.Pow(2, float64(attempts-1))
+	if backoff > float64(callbackMaxBackoff) {
+		return callbackMaxBackoff
+	}
+
+	return time.Duration(backoff)
+}
+
+func toTaskCompletedRequest(task *eiriniv1.Task) TaskCompletedRequest {
+	request := TaskCompletedRequest{TaskGUID: task.Spec.GUID}
+
+	switch task.Status.ExecutionStatus {
+	case eiriniv1.TaskSucceeded:
+		return request
+	case eiriniv1.TaskFailed:
+		request.FailureReason = taskFailedReason
+		if task.Status.FailureReason != "" {
+			request.FailureReason = task.Status.FailureReason
+		}
+	case eiriniv1.TaskCancelled:
+		request.FailureReason = taskCancelledReason
+	default:
+		request.FailureReason = taskDeletedReason
+	}
+
+	request.Failed = true
+
+	return request
+}
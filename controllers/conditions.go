@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"fmt"
+
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/api"
+	"code.cloudfoundry.org/eirini/k8s/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	reasonAllInstancesReady = "AllInstancesReady"
+	reasonInstancesNotReady = "InstancesNotReady"
+	reasonRolloutInProgress = "RolloutInProgress"
+	reasonRolloutComplete   = "RolloutComplete"
+	reasonInstancesCrashed  = "InstancesCrashed"
+	reasonNoCrashes         = "NoCrashes"
+	reasonImagePulled       = "ImagePulled"
+	reasonUnschedulable     = "Unschedulable"
+	reasonScheduled         = "Scheduled"
+	reasonPending           = "Pending"
+	reasonTaskRunning       = "TaskRunning"
+	reasonTaskNotRunning    = "TaskNotRunning"
+	reasonTaskSucceeded     = "TaskSucceeded"
+	reasonTaskFailed        = "TaskFailed"
+	reasonTaskCancelled     = "TaskCancelled"
+	reasonTaskNotCompleted  = "TaskNotCompleted"
+)
+
+func setLRPConditions(status *eiriniv1.LRPStatus, lrp *eiriniv1.LRP, statefulSet *appsv1.StatefulSet, pods []corev1.Pod) {
+	desired := int32(lrp.Spec.Instances)
+	ready := statefulSet.Status.ReadyReplicas
+
+	setCondition(&status.Conditions, lrp.Generation, eiriniv1.LRPConditionReady, ready >= desired,
+		reasonAllInstancesReady, reasonInstancesNotReady, fmt.Sprintf("%d/%d instances ready", ready, desired))
+
+	setCondition(&status.Conditions, lrp.Generation, eiriniv1.LRPConditionProgressing, statefulSetIsRollingOut(statefulSet, desired),
+		reasonRolloutInProgress, reasonRolloutComplete, fmt.Sprintf("%d/%d instances updated", statefulSet.Status.UpdatedReplicas, desired))
+
+	crashed := 0
+
+	for i := range pods {
+		if utils.GetPodState(pods[i]) == api.CrashedState {
+			crashed++
+		}
+	}
+
+	setCondition(&status.Conditions, lrp.Generation, eiriniv1.LRPConditionDegraded, crashed > 0,
+		reasonInstancesCrashed, reasonNoCrashes, fmt.Sprintf("%d instances crashed", crashed))
+
+	imagePullReason, imagePullMessage := findImagePullFailure(pods)
+	setCondition(&status.Conditions, lrp.Generation, eiriniv1.LRPConditionImagePullFailed, imagePullReason != "",
+		imagePullReason, reasonImagePulled, imagePullMessage)
+
+	unschedulableMessage := findUnschedulablePod(pods)
+	setCondition(&status.Conditions, lrp.Generation, eiriniv1.LRPConditionInsufficientResources, unschedulableMessage != "",
+		reasonUnschedulable, reasonScheduled, unschedulableMessage)
+}
+
+func setTaskConditions(status *eiriniv1.TaskStatus, generation int64, pod *corev1.Pod) {
+	scheduled := false
+	scheduledMessage := "the task pod has not been created yet"
+
+	if pod != nil {
+		scheduledMessage = ""
+
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodScheduled {
+				scheduled = condition.Status == corev1.ConditionTrue
+				scheduledMessage = condition.Message
+			}
+		}
+	}
+
+	setCondition(&status.Conditions, generation, eiriniv1.TaskConditionScheduled, scheduled,
+		reasonScheduled, reasonPending, scheduledMessage)
+
+	setTaskExecutionConditions(status, generation)
+}
+
+func setTaskExecutionConditions(status *eiriniv1.TaskStatus, generation int64) {
+	setCondition(&status.Conditions, generation, eiriniv1.TaskConditionRunning, status.ExecutionStatus == eiriniv1.TaskRunning,
+		reasonTaskRunning, reasonTaskNotRunning, "")
+
+	setCondition(&status.Conditions, generation, eiriniv1.TaskConditionComplete, status.ExecutionStatus == eiriniv1.TaskSucceeded,
+		reasonTaskSucceeded, reasonTaskNotCompleted, "")
+
+	failedReason := reasonTaskFailed
+	if status.ExecutionStatus == eiriniv1.TaskCancelled {
+		failedReason = reasonTaskCancelled
+	}
+
+	failed := status.ExecutionStatus == eiriniv1.TaskFailed || status.ExecutionStatus == eiriniv1.TaskCancelled
+	setCondition(&status.Conditions, generation, eiriniv1.TaskConditionFailed, failed,
+		failedReason, reasonTaskNotCompleted, status.FailureReason)
+}
+
+func setCondition(conditions *[]metav1.Condition, generation int64, conditionType string, isTrue bool, trueReason, falseReason, message string) {
+	condition := metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             falseReason,
+		Message:            message,
+	}
+
+	if isTrue {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = trueReason
+	}
+
+	meta.SetStatusCondition(conditions, condition)
+}
+
+func statefulSetIsRollingOut(statefulSet *appsv1.StatefulSet, desired int32) bool {
+	if statefulSet.Status.ObservedGeneration < statefulSet.Generation {
+		return true
+	}
+
+	if statefulSet.Status.UpdateRevision != "" && statefulSet.Status.CurrentRevision != statefulSet.Status.UpdateRevision {
+		return true
+	}
+
+	return statefulSet.Status.UpdatedReplicas < desired || statefulSet.Status.Replicas != desired
+}
+
+func findImagePullFailure(pods []corev1.Pod) (string, string) {
+	for _, pod := range pods {
+		for _, containerStatus := range pod.Status.ContainerStatuses {
+			waiting := containerStatus.State.Waiting
+			if waiting != nil && imagePullFailureReasons[waiting.Reason] {
+				return waiting.Reason, waiting.Message
+			}
+		}
+	}
+
+	return "", ""
+}
+
+func findUnschedulablePod(pods []corev1.Pod) string {
+	for _, pod := range pods {
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodScheduled &&
+				condition.Status == corev1.ConditionFalse &&
+				condition.Reason == corev1.PodReasonUnschedulable {
+				return condition.Message
+			}
+		}
+	}
+
+	return ""
+}
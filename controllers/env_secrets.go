@@ -0,0 +1,314 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/k8s/jobs"
+	"code.cloudfoundry.org/eirini/k8s/stset"
+	"code.cloudfoundry.org/eirini/util"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// AnnotationEnvSecretHash records a hash of the Secrets the environment
+	// of an LRP comes from, so that rotating them restarts the instances
+	AnnotationEnvSecretHash = "eirini.cloudfoundry.org/env-secret-hash"
+
+	lrpSecretIndex = "spec.secretNames"
+)
+
+// envSecretName is the name of the Secret generated for the env of an LRP
+// or Task with EnvInSecret set
+func envSecretName(ownerName string) string {
+	return ownerName + "-env"
+}
+
+// reconcileEnvSecret keeps the generated env Secret of an LRP in line with
+// its env, or deletes it when the LRP does not ask for one. Secrets of the
+// same name that the LRP does not control are never changed. It returns a
+// hash of the data of all the Secrets the env comes from, which is empty
+// when there are none.
+func (r *LRPReconciler) reconcileEnvSecret(ctx context.Context, lrp *eiriniv1.LRP) (string, error) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: lrp.Namespace,
+			Name:      envSecretName(lrp.Name),
+		},
+	}
+
+	if !lrp.Spec.EnvInSecret {
+		if err := deleteIfControlled(ctx, r.Client, secretMetadata(secret.Namespace, secret.Name), lrp); err != nil {
+			return "", errors.Wrap(err, "failed to delete env secret")
+		}
+	} else {
+		_, err := controllerutil.CreateOrUpdate(ctx, apiReadClient{r.Client, r.APIReader}, secret, func() error {
+			if err := ensureControlledBy(secret, lrp); err != nil {
+				return err
+			}
+
+			secret.Labels = map[string]string{
+				stset.LabelGUID:       lrp.Spec.GUID,
+				stset.LabelSourceType: stset.AppSourceType,
+			}
+			secret.Type = corev1.SecretTypeOpaque
+			secret.Data = toSecretData(lrp.Spec.Env)
+
+			return ctrl.SetControllerReference(lrp, secret, r.Scheme)
+		})
+		if err != nil {
+			return "", errors.Wrap(err, "failed to create or update env secret")
+		}
+	}
+
+	secrets := []*corev1.Secret{}
+	if lrp.Spec.EnvInSecret {
+		secrets = append(secrets, secret)
+	}
+
+	for _, name := range referencedSecretNames(lrp.Spec.SecretEnv) {
+		referenced := &corev1.Secret{}
+
+		err := r.APIReader.Get(ctx, client.ObjectKey{Namespace: lrp.Namespace, Name: name}, referenced)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get secret %s", name)
+		}
+
+		secrets = append(secrets, referenced)
+	}
+
+	return hashSecretData(secrets)
+}
+
+// reconcileEnvSecret creates the generated env Secret of a Task that asks
+// for one. A Task is only desired once, so the Secret is never updated. It
+// fails when a Secret of the same name exists that the Task does not control.
+func (r *TaskReconciler) reconcileEnvSecret(ctx context.Context, task *eiriniv1.Task) error {
+	if !task.Spec.EnvInSecret {
+		return nil
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: task.Namespace,
+			Name:      envSecretName(task.Name),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: toSecretData(task.Spec.Env),
+	}
+
+	if err := ctrl.SetControllerReference(task, secret, r.Scheme); err != nil {
+		return errors.Wrap(err, "failed to set controller reference")
+	}
+
+	err := r.Create(ctx, secret)
+	if apierrors.IsAlreadyExists(err) {
+		return r.ensureSecretControlled(ctx, task, secret.Name)
+	}
+
+	return errors.Wrap(err, "failed to create env secret")
+}
+
+// ensureSecretControlled fails for an existing Secret the Task does not
+// control, so that its Job never uses a Secret someone else put there. The
+// Secret is read from the API, as the TaskReconciler does not watch Secrets.
+func (r *TaskReconciler) ensureSecretControlled(ctx context.Context, task *eiriniv1.Task, name string) error {
+	secret := secretMetadata(task.Namespace, name)
+	if err := r.APIReader.Get(ctx, client.ObjectKeyFromObject(secret), secret); err != nil {
+		return errors.Wrapf(err, "failed to get secret %s", name)
+	}
+
+	return ensureControlledBy(secret, task)
+}
+
+// setSecretEnvFn adds the env that comes from Secrets to the app container,
+// and records the hash of the Secret data on the pod template
+func setSecretEnvFn(lrp *eiriniv1.LRP, secretHash string) func(interface{}) error {
+	return func(resource interface{}) error {
+		statefulSet, ok := resource.(*appsv1.StatefulSet)
+		if !ok {
+			return fmt.Errorf("failed to cast %v to appsv1.StatefulSet", resource)
+		}
+
+		envs := secretEnvVars(envSecretName(lrp.Name), lrp.Spec.Env, lrp.Spec.EnvInSecret, lrp.Spec.SecretEnv)
+
+		containers := statefulSet.Spec.Template.Spec.Containers
+		for i := range containers {
+			if containers[i].Name == stset.ApplicationContainerName {
+				containers[i].Env = append(containers[i].Env, envs...)
+			}
+		}
+
+		if secretHash != "" {
+			if statefulSet.Spec.Template.Annotations == nil {
+				statefulSet.Spec.Template.Annotations = map[string]string{}
+			}
+
+			statefulSet.Spec.Template.Annotations[AnnotationEnvSecretHash] = secretHash
+		}
+
+		return nil
+	}
+}
+
+func setTaskSecretEnvFn(task *eiriniv1.Task) func(interface{}) error {
+	return func(resource interface{}) error {
+		job, ok := resource.(*batchv1.Job)
+		if !ok {
+			return fmt.Errorf("failed to cast %v to batchv1.Job", resource)
+		}
+
+		envs := secretEnvVars(envSecretName(task.Name), task.Spec.Env, task.Spec.EnvInSecret, task.Spec.SecretEnv)
+
+		containerName := job.Spec.Template.Annotations[jobs.AnnotationTaskContainerName]
+		containers := job.Spec.Template.Spec.Containers
+		for i := range containers {
+			if containers[i].Name == containerName {
+				containers[i].Env = append(containers[i].Env, envs...)
+			}
+		}
+
+		return nil
+	}
+}
+
+func secretEnvVars(envSecret string, env map[string]string, envInSecret bool, secretEnv []eiriniv1.SecretEnvVar) []corev1.EnvVar {
+	envs := []corev1.EnvVar{}
+
+	if envInSecret {
+		names := make([]string, 0, len(env))
+		for name := range env {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			envs = append(envs, secretKeyEnvVar(name, envSecret, name))
+		}
+	}
+
+	for _, e := range secretEnv {
+		envs = append(envs, secretKeyEnvVar(e.Name, e.SecretName, e.Key))
+	}
+
+	return envs
+}
+
+func secretKeyEnvVar(name, secretName, key string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+				Key:                  key,
+			},
+		},
+	}
+}
+
+func toSecretData(env map[string]string) map[string][]byte {
+	data := map[string][]byte{}
+	for name, value := range env {
+		data[name] = []byte(value)
+	}
+
+	return data
+}
+
+func referencedSecretNames(secretEnv []eiriniv1.SecretEnvVar) []string {
+	seen := map[string]bool{}
+	names := []string{}
+
+	for _, e := range secretEnv {
+		if !seen[e.SecretName] {
+			seen[e.SecretName] = true
+			names = append(names, e.SecretName)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func hashSecretData(secrets []*corev1.Secret) (string, error) {
+	if len(secrets) == 0 {
+		return "", nil
+	}
+
+	var b strings.Builder
+
+	for _, secret := range secrets {
+		keys := make([]string, 0, len(secret.Data))
+		for key := range secret.Data {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		fmt.Fprintf(&b, "%s\n", secret.Name)
+
+		for _, key := range keys {
+			fmt.Fprintf(&b, "%s=%x\n", key, secret.Data[key])
+		}
+	}
+
+	hash, err := util.Hash(b.String())
+
+	return hash, errors.Wrap(err, "failed to hash env secrets")
+}
+
+func indexLRPSecrets(obj client.Object) []string {
+	lrp, ok := obj.(*eiriniv1.LRP)
+	if !ok {
+		return nil
+	}
+
+	names := referencedSecretNames(lrp.Spec.SecretEnv)
+	if lrp.Spec.PrivateRegistry != nil && lrp.Spec.PrivateRegistry.SecretName != "" {
+		names = append(names, lrp.Spec.PrivateRegistry.SecretName)
+	}
+
+	return names
+}
+
+// secretToLRPs maps a Secret to the LRPs that take env or registry
+// credentials from it, so that rotating it restarts their instances or
+// updates their pull secret
+func (r *LRPReconciler) secretToLRPs(obj client.Object) []reconcile.Request {
+	lrps := eiriniv1.LRPList{}
+
+	err := r.List(context.Background(), &lrps,
+		client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{lrpSecretIndex: obj.GetName()},
+	)
+	if err != nil {
+		r.Logger.Error("failed-to-list-lrps-for-secret", err)
+
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(lrps.Items))
+	for i := range lrps.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&lrps.Items[i])})
+	}
+
+	return requests
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"code.cloudfoundry.org/eirini"
+	"code.cloudfoundry.org/eirini/k8s/stset"
+	"code.cloudfoundry.org/eirini/util"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const InstanceIndexWebhookPath = "/mutate-v1-pod"
+
+//+kubebuilder:webhook:path=/mutate-v1-pod,mutating=true,failurePolicy=fail,sideEffects=None,groups="",resources=pods,verbs=create,versions=v1,name=minstanceindex.eirini.cloudfoundry.org,admissionReviewVersions={v1,v1beta1}
+
+// InstanceIndexInjector sets CF_INSTANCE_INDEX on the containers of LRP
+// pods to the ordinal the StatefulSet gave the pod. It ignores all other pods.
+// The webhook configuration only sends it app pods (see
+// config/webhook/instance_index_webhook_patch.yaml), and fails their creation
+// while the webhook is down, so that no instance starts without its index.
+type InstanceIndexInjector struct {
+	decoder *admission.Decoder
+}
+
+func (i *InstanceIndexInjector) Handle(ctx context.Context, req admission.Request) admission.Response {
+	pod := &corev1.Pod{}
+	if err := i.decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if pod.Labels[stset.LabelSourceType] != stset.AppSourceType {
+		return admission.Allowed("not an lrp pod")
+	}
+
+	index, err := util.ParseAppIndex(pod.Name)
+	if err != nil {
+		return admission.Allowed("pod name does not contain an instance index")
+	}
+
+	for c := range pod.Spec.Containers {
+		setEnvIfMissing(&pod.Spec.Containers[c], eirini.EnvCFInstanceIndex, strconv.Itoa(index))
+	}
+
+	marshaledPod, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaledPod)
+}
+
+// InjectDecoder implements admission.DecoderInjector
+func (i *InstanceIndexInjector) InjectDecoder(d *admission.Decoder) error {
+	i.decoder = d
+
+	return nil
+}
+
+func setEnvIfMissing(container *corev1.Container, name, value string) {
+	for _, env := range container.Env {
+		if env.Name == name {
+			return
+		}
+	}
+
+	container.Env = append(container.Env, corev1.EnvVar{Name: name, Value: value})
+}
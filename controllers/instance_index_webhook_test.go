@@ -0,0 +1,77 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+
+	"code.cloudfoundry.org/eirini-controller/controllers"
+	"code.cloudfoundry.org/eirini/k8s/stset"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("InstanceIndexInjector", func() {
+	var (
+		injector *controllers.InstanceIndexInjector
+		pod      *corev1.Pod
+	)
+
+	BeforeEach(func() {
+		injector = &controllers.InstanceIndexInjector{}
+		decoder, err := admission.NewDecoder(scheme.Scheme)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(injector.InjectDecoder(decoder)).To(Succeed())
+
+		pod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "my-app-space-1234-3",
+				Labels: map[string]string{stset.LabelSourceType: stset.AppSourceType},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: stset.ApplicationContainerName}},
+			},
+		}
+	})
+
+	handle := func() admission.Response {
+		raw, err := json.Marshal(pod)
+		Expect(err).NotTo(HaveOccurred())
+
+		return injector.Handle(context.Background(), admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				Object:    runtime.RawExtension{Raw: raw},
+			},
+		})
+	}
+
+	It("sets CF_INSTANCE_INDEX to the pod ordinal", func() {
+		response := handle()
+
+		Expect(response.Allowed).To(BeTrue())
+		Expect(response.Patches).To(HaveLen(1))
+		Expect(response.Patches[0].Value).To(ContainElement(map[string]interface{}{
+			"name":  "CF_INSTANCE_INDEX",
+			"value": "3",
+		}))
+	})
+
+	When("the pod is not an lrp pod", func() {
+		BeforeEach(func() {
+			pod.Labels = nil
+		})
+
+		It("leaves it alone", func() {
+			response := handle()
+
+			Expect(response.Allowed).To(BeTrue())
+			Expect(response.Patches).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"context"
+
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"github.com/pkg/errors"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// reconcileAutoscaler keeps a HorizontalPodAutoscaler targeting the scale
+// subresource of the LRP in line with its autoscaling spec, and deletes it
+// once autoscaling is turned off.
+func (r *LRPReconciler) reconcileAutoscaler(ctx context.Context, lrp *eiriniv1.LRP) error {
+	hpa := &autoscalingv2beta2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: lrp.Namespace,
+			Name:      lrp.Name,
+		},
+	}
+
+	if lrp.Spec.Autoscaling == nil {
+		err := deleteIfControlled(ctx, r.Client, hpa, lrp)
+
+		return errors.Wrap(err, "failed to delete horizontal pod autoscaler")
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, hpa, func() error {
+		if err := ensureControlledBy(hpa, lrp); err != nil {
+			return err
+		}
+
+		hpa.Spec = toHPASpec(lrp)
+
+		return ctrl.SetControllerReference(lrp, hpa, r.Scheme)
+	})
+
+	return errors.Wrap(err, "failed to create or update horizontal pod autoscaler")
+}
+
+func toHPASpec(lrp *eiriniv1.LRP) autoscalingv2beta2.HorizontalPodAutoscalerSpec {
+	autoscaling := lrp.Spec.Autoscaling
+
+	spec := autoscalingv2beta2.HorizontalPodAutoscalerSpec{
+		ScaleTargetRef: autoscalingv2beta2.CrossVersionObjectReference{
+			APIVersion: eiriniv1.GroupVersion.String(),
+			Kind:       "LRP",
+			Name:       lrp.Name,
+		},
+		MinReplicas: autoscaling.MinInstances,
+		MaxReplicas: autoscaling.MaxInstances,
+	}
+
+	if autoscaling.TargetCPUUtilizationPercentage != nil {
+		spec.Metrics = append(spec.Metrics, resourceUtilizationMetric(corev1.ResourceCPU, *autoscaling.TargetCPUUtilizationPercentage))
+	}
+
+	if autoscaling.TargetMemoryUtilizationPercentage != nil {
+		spec.Metrics = append(spec.Metrics, resourceUtilizationMetric(corev1.ResourceMemory, *autoscaling.TargetMemoryUtilizationPercentage))
+	}
+
+	return spec
+}
+
+func resourceUtilizationMetric(resource corev1.ResourceName, utilization int32) autoscalingv2beta2.MetricSpec {
+	return autoscalingv2beta2.MetricSpec{
+		Type: autoscalingv2beta2.ResourceMetricSourceType,
+		Resource: &autoscalingv2beta2.ResourceMetricSource{
+			Name: resource,
+			Target: autoscalingv2beta2.MetricTarget{
+				Type:               autoscalingv2beta2.UtilizationMetricType,
+				AverageUtilization: &utilization,
+			},
+		},
+	}
+}
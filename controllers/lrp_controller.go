@@ -19,46 +19,79 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/jinzhu/copier"
 	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"code.cloudfoundry.org/eirini"
 	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
 	"code.cloudfoundry.org/eirini/api"
-	"code.cloudfoundry.org/eirini/k8s/reconciler"
-	"code.cloudfoundry.org/eirini/util"
+	"code.cloudfoundry.org/eirini/k8s/stset"
 	"code.cloudfoundry.org/lager"
 )
 
 // LRPReconciler reconciles a LRP object
 type LRPReconciler struct {
 	client.Client
-	Logger         lager.Logger
-	Scheme         *runtime.Scheme
-	WorkloadClient reconciler.LRPWorkloadCLient
+	// APIReader reads the full Secrets the cache only holds the metadata of
+	APIReader            client.Reader
+	Logger               lager.Logger
+	Scheme               *runtime.Scheme
+	WorkloadClient       LRPWorkloadClient
+	StatefulSetConverter stset.LRPToStatefulSetConverter
+	RateLimiter          ratelimiter.RateLimiter
+	// VersionReadyThreshold is the percentage of instances of a new version
+	// that must be ready before the previous version is stopped
+	VersionReadyThreshold int
+	// VersionTransitionTimeout is how long a new version may take to become
+	// ready before it is rolled back. 0 means it is never rolled back.
+	VersionTransitionTimeout time.Duration
+	// LRPDeletionGracePeriod is how long the deletion of an LRP waits for its
+	// pods to terminate before cleaning up the rest of its resources
+	LRPDeletionGracePeriod time.Duration
+	// IngressClassName is the class of the Ingresses created for LRP routes.
+	// When empty the cluster default is used.
+	IngressClassName string
+	Recorder         record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=eirini.cloudfoundry.org,resources=lrps,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=eirini.cloudfoundry.org,resources=lrps/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=eirini.cloudfoundry.org,resources=lrps/finalizers,verbs=update
 //+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;watch;list
-//+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=create;update
-
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the LRP object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+//+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=create;update;patch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile desires a StatefulSet for every version of an LRP and keeps its
+// pod template, Secrets, Service, Ingress and autoscaler in line with the
+// LRP spec, mirroring the state of its instances back into the LRP status.
+// Old versions are retired once the new one is ready, and the workloads of
+// deleted LRPs are cleaned up through a finalizer.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.8.3/pkg/reconcile
@@ -79,16 +112,43 @@ func (r *LRPReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		return reconcile.Result{}, client.IgnoreNotFound(err)
 	}
 
+	if !lrp.DeletionTimestamp.IsZero() {
+		result, err := r.handleDeletedLRP(ctx, logger, &lrp)
+		if err != nil {
+			logger.Error("failed-to-clean-up-lrp", err)
+		}
+
+		return result, err
+	}
+
+	if err := r.addLRPFinalizer(ctx, &lrp); err != nil {
+		logger.Error("failed-to-add-finalizer", err)
+		return reconcile.Result{}, err
+	}
+
 	if err := r.do(ctx, &lrp); err != nil {
 		logger.Error("failed-to-reconcile", err)
 		return reconcile.Result{}, err
 	}
 
+	if versionTransitionInProgress(&lrp) {
+		return reconcile.Result{RequeueAfter: versionTransitionPollInterval}, nil
+	}
+
 	return reconcile.Result{}, nil
 }
 
 func (r *LRPReconciler) do(ctx context.Context, lrp *eiriniv1.LRP) error {
-	_, err := r.WorkloadClient.Get(ctx, api.LRPIdentifier{
+	if lrp.Spec.Version == lrp.Status.FailedVersion {
+		return r.updateRolledBackStatus(ctx, lrp)
+	}
+
+	secrets, err := r.reconcileSecrets(ctx, lrp)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.WorkloadClient.Get(ctx, api.LRPIdentifier{
 		GUID:    lrp.Spec.GUID,
 		Version: lrp.Spec.Version,
 	})
@@ -98,7 +158,7 @@ func (r *LRPReconciler) do(ctx context.Context, lrp *eiriniv1.LRP) error {
 			return errors.Wrap(parseErr, "failed to parse the crd spec to the lrp model")
 		}
 
-		return errors.Wrap(r.WorkloadClient.Desire(ctx, lrp.Namespace, appLRP, r.setOwnerFn(lrp)), "failed to desire lrp")
+		return errors.Wrap(r.WorkloadClient.Desire(ctx, lrp.Namespace, appLRP, r.statefulSetOptions(lrp, secrets)...), "failed to desire lrp")
 	}
 
 	if err != nil {
@@ -115,9 +175,18 @@ func (r *LRPReconciler) do(ctx context.Context, lrp *eiriniv1.LRP) error {
 	err = r.updateStatus(ctx, lrp)
 	errs = multierror.Append(errs, errors.Wrap(err, "failed to update lrp status"))
 
+	err = r.updateStatefulSet(ctx, lrp, appLRP, secrets)
+	errs = multierror.Append(errs, errors.Wrap(err, "failed to update statefulset"))
+
 	err = r.WorkloadClient.Update(ctx, appLRP)
 	errs = multierror.Append(errs, errors.Wrap(err, "failed to update app"))
 
+	err = r.reconcileAutoscaler(ctx, lrp)
+	errs = multierror.Append(errs, errors.Wrap(err, "failed to reconcile autoscaler"))
+
+	err = r.reconcileIngress(ctx, lrp)
+	errs = multierror.Append(errs, errors.Wrap(err, "failed to reconcile ingress"))
+
 	return errs.ErrorOrNil()
 }
 
@@ -130,11 +199,39 @@ func (r *LRPReconciler) updateStatus(ctx context.Context, lrp *eiriniv1.LRP) err
 		return err
 	}
 
-	actualStaus := eiriniv1.LRPStatus{
-		Replicas: lrpStatus.Replicas,
+	actualStatus := lrp.Status.DeepCopy()
+	actualStatus.Replicas = lrpStatus.Replicas
+	actualStatus.ObservedGeneration = lrp.Generation
+	actualStatus.Selector = labels.SelectorFromSet(labels.Set(lrpLabels(lrp))).String()
+
+	pods, err := r.getPods(ctx, lrp)
+	if err != nil {
+		return err
+	}
+
+	applyInstanceStatuses(actualStatus, pods)
+
+	statefulSet, err := r.getStatefulSet(ctx, lrp)
+	if err != nil {
+		return err
+	}
+
+	if statefulSet != nil {
+		setLRPConditions(actualStatus, lrp, statefulSet, pods)
 	}
 
-	return r.UpdateLRPStatus(ctx, lrp, actualStaus)
+	var errs *multierror.Error
+
+	err = r.handleInstanceRestarts(ctx, lrp, actualStatus)
+	errs = multierror.Append(errs, errors.Wrap(err, "failed to restart instances"))
+
+	err = r.transitionVersions(ctx, lrp, actualStatus, statefulSet)
+	errs = multierror.Append(errs, errors.Wrap(err, "failed to transition versions"))
+
+	err = r.UpdateLRPStatus(ctx, lrp, *actualStatus)
+	errs = multierror.Append(errs, err)
+
+	return errs.ErrorOrNil()
 }
 
 func (r *LRPReconciler) UpdateLRPStatus(ctx context.Context, lrp *eiriniv1.LRP, newStatus eiriniv1.LRPStatus) error {
@@ -167,17 +264,51 @@ func toAPILrp(lrp *eiriniv1.LRP) (*api.LRP, error) {
 
 	apiLrp.TargetInstances = lrp.Spec.Instances
 
-	if lrp.Spec.PrivateRegistry != nil {
-		apiLrp.PrivateRegistry.Server = util.ParseImageRegistryHost(lrp.Spec.Image)
+	if lrp.Spec.EnvInSecret {
+		apiLrp.Env = nil
 	}
 
+	// the controller generates and keeps the pull secret in sync itself, see
+	// reconcileRegistrySecret
+	apiLrp.PrivateRegistry = nil
+
 	return apiLrp, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *LRPReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &eiriniv1.LRP{}, lrpIdentifierIndex, indexLRPIdentifier)
+	if err != nil {
+		return errors.Wrap(err, "failed to index lrps by identifier")
+	}
+
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &eiriniv1.LRP{}, lrpSecretIndex, indexLRPSecrets)
+	if err != nil {
+		return errors.Wrap(err, "failed to index lrps by secret")
+	}
+
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), secretMetadata("", ""), registrySecretOwnerIndex, indexRegistrySecretOwners)
+	if err != nil {
+		return errors.Wrap(err, "failed to index registry secrets by owner")
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&eiriniv1.LRP{}).
 		Owns(&appsv1.StatefulSet{}).
+		Owns(&autoscalingv2beta2.HorizontalPodAutoscaler{}).
+		Owns(&corev1.Service{}).
+		Owns(&networkingv1.Ingress{}).
+		Owns(&corev1.Secret{}, builder.OnlyMetadata).
+		Watches(
+			&source.Kind{Type: &corev1.Pod{}},
+			rateLimitedHandler{handler.EnqueueRequestsFromMapFunc(r.podToLRP)},
+			builder.WithPredicates(lrpPodPredicate),
+		).
+		Watches(
+			&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.secretToLRPs),
+			builder.OnlyMetadata,
+		).
+		WithOptions(controller.Options{RateLimiter: r.RateLimiter}).
 		Complete(r)
 }
@@ -2,13 +2,20 @@ package controllers_test
 
 import (
 	"context"
+	"encoding/json"
 
 	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini-controller/controllers"
+	"code.cloudfoundry.org/eirini/k8s/stset"
 	uuid "github.com/hashicorp/go-uuid"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -52,8 +59,670 @@ var _ = Describe("LrpController", func() {
 		Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
 		Eventually(getStatefulSetItems(ctx, lrpNamespace)).Should(HaveLen(1))
 	})
+
+	When("the lrp spec changes", func() {
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+			Eventually(getStatefulSetItems(ctx, lrpNamespace)).Should(HaveLen(1))
+
+			Eventually(func() error {
+				current := &eiriniv1.LRP{}
+				if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(lrp), current); err != nil {
+					return err
+				}
+
+				current.Spec.Env = map[string]string{"FOO": "bar"}
+				current.Spec.MemoryMB = 512
+
+				return k8sClient.Update(ctx, current)
+			}).Should(Succeed())
+		})
+
+		It("rolls the change out to the statefulset pod template", func() {
+			Eventually(func() ([]corev1.Container, error) {
+				statefulSets, err := getStatefulSetItems(ctx, lrpNamespace)()
+				if err != nil || len(statefulSets) != 1 {
+					return nil, err
+				}
+
+				return statefulSets[0].Spec.Template.Spec.Containers, nil
+			}).Should(ContainElement(SatisfyAll(
+				WithTransform(func(c corev1.Container) []corev1.EnvVar { return c.Env }, ContainElement(corev1.EnvVar{Name: "FOO", Value: "bar"})),
+				WithTransform(func(c corev1.Container) int64 { return c.Resources.Limits.Memory().ScaledValue(resource.Mega) }, Equal(int64(512))),
+			)))
+		})
+	})
+
+	When("the lrp version changes", func() {
+		var oldVersion, newVersion string
+
+		BeforeEach(func() {
+			lrp.Spec.Instances = 1
+			lrp.Spec.Ports = []int32{8080}
+			oldVersion = lrp.Spec.Version
+			newVersion = GenerateGUID()
+
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+			Eventually(getActiveVersion(ctx, lrp)).Should(Equal(oldVersion))
+
+			Eventually(func() error {
+				current := &eiriniv1.LRP{}
+				if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(lrp), current); err != nil {
+					return err
+				}
+
+				current.Spec.Version = newVersion
+
+				return k8sClient.Update(ctx, current)
+			}).Should(Succeed())
+		})
+
+		It("keeps the old version active until the new one is ready", func() {
+			Eventually(getStatefulSetItems(ctx, lrpNamespace)).Should(HaveLen(2))
+			Consistently(getActiveVersion(ctx, lrp), "2s").Should(Equal(oldVersion))
+			Eventually(getServiceSelector(ctx, lrp)).Should(HaveKeyWithValue(stset.LabelVersion, oldVersion))
+		})
+
+		When("the new version becomes ready", func() {
+			BeforeEach(func() {
+				Eventually(getStatefulSetItems(ctx, lrpNamespace)).Should(HaveLen(2))
+
+				Eventually(func() error {
+					statefulSets, err := getStatefulSetItems(ctx, lrpNamespace)()
+					if err != nil {
+						return err
+					}
+
+					for i := range statefulSets {
+						if statefulSets[i].Labels[stset.LabelVersion] == newVersion {
+							statefulSets[i].Status.Replicas = 1
+							statefulSets[i].Status.ReadyReplicas = 1
+
+							return k8sClient.Status().Update(ctx, &statefulSets[i])
+						}
+					}
+
+					return nil
+				}).Should(Succeed())
+			})
+
+			It("makes it active and stops the old version", func() {
+				Eventually(getActiveVersion(ctx, lrp)).Should(Equal(newVersion))
+				Eventually(func() ([]string, error) {
+					statefulSets, err := getStatefulSetItems(ctx, lrpNamespace)()
+					versions := []string{}
+					for _, statefulSet := range statefulSets {
+						versions = append(versions, statefulSet.Labels[stset.LabelVersion])
+					}
+
+					return versions, err
+				}).Should(ConsistOf(newVersion))
+			})
+
+			It("points the service at it", func() {
+				Eventually(getServiceSelector(ctx, lrp)).Should(HaveKeyWithValue(stset.LabelVersion, newVersion))
+			})
+		})
+	})
+
+	When("instance restarts are requested", func() {
+		BeforeEach(func() {
+			lrp.Spec.Instances = 1
+			lrp.Spec.InstanceRestarts = []eiriniv1.InstanceRestartRequest{
+				{ID: "restart-0", Index: 0},
+				{ID: "restart-5", Index: 5},
+			}
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+		})
+
+		It("records the requests as handled, with an error for invalid indices", func() {
+			Eventually(func() (map[string]string, error) {
+				current := &eiriniv1.LRP{}
+				err := k8sClient.Get(ctx, client.ObjectKeyFromObject(lrp), current)
+
+				handled := map[string]string{}
+				for _, restart := range current.Status.HandledInstanceRestarts {
+					handled[restart.ID] = restart.Error
+				}
+
+				return handled, err
+			}).Should(Equal(map[string]string{
+				"restart-0": "",
+				"restart-5": "invalid instance index",
+			}))
+		})
+	})
+
+	When("autoscaling is enabled", func() {
+		BeforeEach(func() {
+			targetCPU := int32(80)
+			lrp.Spec.Autoscaling = &eiriniv1.Autoscaling{
+				MaxInstances:                   5,
+				TargetCPUUtilizationPercentage: &targetCPU,
+			}
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+		})
+
+		It("creates a horizontal pod autoscaler targeting the lrp", func() {
+			hpa := &autoscalingv2beta2.HorizontalPodAutoscaler{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, client.ObjectKeyFromObject(lrp), hpa)
+			}).Should(Succeed())
+
+			Expect(hpa.Spec.MaxReplicas).To(Equal(int32(5)))
+			Expect(hpa.Spec.ScaleTargetRef.Kind).To(Equal("LRP"))
+			Expect(hpa.Spec.ScaleTargetRef.Name).To(Equal(lrp.Name))
+			Expect(hpa.Spec.Metrics).To(HaveLen(1))
+			Expect(*hpa.Spec.Metrics[0].Resource.Target.AverageUtilization).To(Equal(int32(80)))
+			Expect(hpa.OwnerReferences).To(HaveLen(1))
+		})
+	})
+
+	When("the lrp has a health check with a start timeout", func() {
+		BeforeEach(func() {
+			period := int32(15)
+			lrp.Spec.Ports = []int32{8080}
+			lrp.Spec.Health = eiriniv1.Healthcheck{
+				Type:          eiriniv1.HealthcheckTypeHTTP,
+				Port:          8080,
+				Endpoint:      "/health",
+				TimeoutMs:     60000,
+				PeriodSeconds: &period,
+			}
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+		})
+
+		It("gives the app container a startup probe covering the start timeout", func() {
+			Eventually(getStatefulSetItems(ctx, lrpNamespace)).Should(HaveLen(1))
+
+			statefulSets, err := getStatefulSetItems(ctx, lrpNamespace)()
+			Expect(err).NotTo(HaveOccurred())
+
+			container := statefulSets[0].Spec.Template.Spec.Containers[0]
+			Expect(container.StartupProbe).NotTo(BeNil())
+			Expect(container.StartupProbe.PeriodSeconds * container.StartupProbe.FailureThreshold).To(Equal(int32(60)))
+			Expect(container.LivenessProbe.InitialDelaySeconds).To(BeZero())
+			Expect(container.LivenessProbe.PeriodSeconds).To(Equal(int32(15)))
+			Expect(container.ReadinessProbe.HTTPGet.Path).To(Equal("/health"))
+		})
+	})
+
+	When("the lrp has sidecars", func() {
+		BeforeEach(func() {
+			lrp.Spec.Sidecars = []eiriniv1.Sidecar{
+				{
+					Name:     "proxy",
+					Image:    "envoyproxy/envoy",
+					Command:  []string{"envoy"},
+					MemoryMB: 64,
+					Ports:    []int32{9901},
+					Start:    eiriniv1.SidecarStartBeforeApp,
+				},
+				{
+					Name:     "logger",
+					Command:  []string{"forward-logs"},
+					MemoryMB: 32,
+				},
+			}
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+		})
+
+		It("orders them around the app container and honours their own image and ports", func() {
+			Eventually(getStatefulSetItems(ctx, lrpNamespace)).Should(HaveLen(1))
+
+			statefulSets, err := getStatefulSetItems(ctx, lrpNamespace)()
+			Expect(err).NotTo(HaveOccurred())
+
+			containers := statefulSets[0].Spec.Template.Spec.Containers
+			Expect(containers).To(HaveLen(3))
+			Expect(containers[0].Name).To(Equal("proxy"))
+			Expect(containers[0].Image).To(Equal("envoyproxy/envoy"))
+			Expect(containers[0].Ports).To(ConsistOf(corev1.ContainerPort{ContainerPort: 9901, Protocol: corev1.ProtocolTCP}))
+			Expect(containers[1].Name).To(Equal(stset.ApplicationContainerName))
+			Expect(containers[2].Name).To(Equal("logger"))
+			Expect(containers[2].Image).To(Equal(lrp.Spec.Image))
+		})
+	})
+
+	When("the lrp mounts a config map and an empty dir", func() {
+		BeforeEach(func() {
+			lrp.Spec.VolumeMounts = []eiriniv1.VolumeMount{
+				{
+					MountPath: "/etc/app",
+					ReadOnly:  true,
+					SubPath:   "app.yml",
+					ConfigMap: &eiriniv1.ConfigMapVolumeSource{Name: "app-config"},
+				},
+				{
+					MountPath: "/tmp/cache",
+					EmptyDir:  &eiriniv1.EmptyDirVolumeSource{Medium: "Memory"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+		})
+
+		It("adds the volumes to the statefulset and mounts them in the app container", func() {
+			Eventually(getStatefulSetItems(ctx, lrpNamespace)).Should(HaveLen(1))
+
+			statefulSets, err := getStatefulSetItems(ctx, lrpNamespace)()
+			Expect(err).NotTo(HaveOccurred())
+
+			podSpec := statefulSets[0].Spec.Template.Spec
+			volumes := map[string]corev1.VolumeSource{}
+			for _, volume := range podSpec.Volumes {
+				volumes[volume.Name] = volume.VolumeSource
+			}
+			Expect(volumes).To(HaveKey("configmap-0"))
+			Expect(volumes["configmap-0"].ConfigMap.Name).To(Equal("app-config"))
+			Expect(volumes).To(HaveKey("emptydir-1"))
+			Expect(volumes["emptydir-1"].EmptyDir.Medium).To(Equal(corev1.StorageMediumMemory))
+			Expect(podSpec.Containers[0].VolumeMounts).To(ContainElements(
+				corev1.VolumeMount{Name: "configmap-0", MountPath: "/etc/app", ReadOnly: true, SubPath: "app.yml"},
+				corev1.VolumeMount{Name: "emptydir-1", MountPath: "/tmp/cache"},
+			))
+		})
+	})
+
+	When("the lrp mounts the same claim twice with different subpaths", func() {
+		BeforeEach(func() {
+			lrp.Spec.VolumeMounts = []eiriniv1.VolumeMount{
+				{MountPath: "/data/uploads", ClaimName: "app-data", SubPath: "uploads"},
+				{MountPath: "/data/reports", ClaimName: "app-data", SubPath: "reports", ReadOnly: true},
+			}
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+		})
+
+		It("adds a single volume for the claim and mounts it twice", func() {
+			Eventually(getStatefulSetItems(ctx, lrpNamespace)).Should(HaveLen(1))
+
+			statefulSets, err := getStatefulSetItems(ctx, lrpNamespace)()
+			Expect(err).NotTo(HaveOccurred())
+
+			podSpec := statefulSets[0].Spec.Template.Spec
+			Expect(podSpec.Volumes).To(ConsistOf(corev1.Volume{
+				Name: "app-data",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "app-data"},
+				},
+			}))
+			Expect(podSpec.Containers[0].VolumeMounts).To(ContainElements(
+				corev1.VolumeMount{Name: "app-data", MountPath: "/data/uploads", SubPath: "uploads"},
+				corev1.VolumeMount{Name: "app-data", MountPath: "/data/reports", SubPath: "reports", ReadOnly: true},
+			))
+		})
+	})
+
+	When("the lrp takes env from a secret named like a generated env secret", func() {
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: lrpNamespace, Name: lrpName + "-env"},
+				Data:       map[string][]byte{"password": []byte("hunter2")},
+			})).To(Succeed())
+
+			lrp.Spec.SecretEnv = []eiriniv1.SecretEnvVar{{Name: "DB_PASSWORD", SecretName: lrpName + "-env", Key: "password"}}
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+		})
+
+		It("leaves the secret alone", func() {
+			Eventually(getStatefulSetItems(ctx, lrpNamespace)).Should(HaveLen(1))
+
+			Consistently(func() (map[string][]byte, error) {
+				secret := &corev1.Secret{}
+				err := k8sClient.Get(ctx, client.ObjectKey{Namespace: lrpNamespace, Name: lrpName + "-env"}, secret)
+
+				return secret.Data, err
+			}, "2s").Should(HaveKeyWithValue("password", []byte("hunter2")))
+		})
+	})
+
+	When("the lrp keeps its env in a secret and takes env from another secret", func() {
+		var credentials *corev1.Secret
+
+		BeforeEach(func() {
+			credentials = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: lrpNamespace, Name: "db-credentials"},
+				Data:       map[string][]byte{"password": []byte("hunter2")},
+			}
+			Expect(k8sClient.Create(ctx, credentials)).To(Succeed())
+
+			lrp.Spec.Env = map[string]string{"VCAP_SERVICES": "{}"}
+			lrp.Spec.EnvInSecret = true
+			lrp.Spec.SecretEnv = []eiriniv1.SecretEnvVar{{Name: "DB_PASSWORD", SecretName: "db-credentials", Key: "password"}}
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+		})
+
+		getAppContainer := func() (corev1.Container, map[string]string, error) {
+			statefulSets, err := getStatefulSetItems(ctx, lrpNamespace)()
+			if err != nil || len(statefulSets) == 0 {
+				return corev1.Container{}, nil, err
+			}
+
+			template := statefulSets[0].Spec.Template
+			for _, container := range template.Spec.Containers {
+				if container.Name == stset.ApplicationContainerName {
+					return container, template.Annotations, nil
+				}
+			}
+
+			return corev1.Container{}, template.Annotations, nil
+		}
+
+		It("references the secrets instead of putting the values in the statefulset", func() {
+			Eventually(getStatefulSetItems(ctx, lrpNamespace)).Should(HaveLen(1))
+
+			envSecret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: lrpNamespace, Name: lrpName + "-env"}, envSecret)).To(Succeed())
+			Expect(envSecret.Data).To(HaveKeyWithValue("VCAP_SERVICES", []byte("{}")))
+
+			container, _, err := getAppContainer()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(container.Env).To(ContainElements(
+				corev1.EnvVar{Name: "VCAP_SERVICES", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: lrpName + "-env"},
+					Key:                  "VCAP_SERVICES",
+				}}},
+				corev1.EnvVar{Name: "DB_PASSWORD", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "db-credentials"},
+					Key:                  "password",
+				}}},
+			))
+		})
+
+		It("restarts the instances when a secret is rotated", func() {
+			var hash string
+			Eventually(func() (string, error) {
+				_, annotations, err := getAppContainer()
+				hash = annotations[controllers.AnnotationEnvSecretHash]
+
+				return hash, err
+			}).ShouldNot(BeEmpty())
+
+			credentials.Data["password"] = []byte("correct-horse")
+			Expect(k8sClient.Update(ctx, credentials)).To(Succeed())
+
+			Eventually(func() (string, error) {
+				_, annotations, err := getAppContainer()
+
+				return annotations[controllers.AnnotationEnvSecretHash], err
+			}).ShouldNot(Equal(hash))
+		})
+	})
+
+	When("the lrp takes its registry credentials from a basic-auth secret", func() {
+		var credentials *corev1.Secret
+
+		BeforeEach(func() {
+			credentials = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: lrpNamespace, Name: "registry-credentials"},
+				Type:       corev1.SecretTypeBasicAuth,
+				Data: map[string][]byte{
+					corev1.BasicAuthUsernameKey: []byte("user"),
+					corev1.BasicAuthPasswordKey: []byte("hunter2"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, credentials)).To(Succeed())
+
+			lrp.Spec.Image = "registry.example.com/app:latest"
+			lrp.Spec.PrivateRegistry = &eiriniv1.PrivateRegistry{SecretName: "registry-credentials"}
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+		})
+
+		getPullSecretConfig := func() (string, error) {
+			pullSecret := &corev1.Secret{}
+			err := k8sClient.Get(ctx, client.ObjectKey{Namespace: lrpNamespace, Name: lrpName + "-registry"}, pullSecret)
+
+			return string(pullSecret.Data[corev1.DockerConfigJsonKey]), err
+		}
+
+		It("generates a pull secret and uses it in the statefulset", func() {
+			Eventually(getPullSecretConfig).Should(ContainSubstring(`"password":"hunter2"`))
+
+			Eventually(getStatefulSetItems(ctx, lrpNamespace)).Should(HaveLen(1))
+			statefulSets, err := getStatefulSetItems(ctx, lrpNamespace)()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(statefulSets[0].Spec.Template.Spec.ImagePullSecrets).To(ContainElement(corev1.LocalObjectReference{Name: lrpName + "-registry"}))
+		})
+
+		It("keeps the pull secret in sync with the credentials", func() {
+			Eventually(getPullSecretConfig).Should(ContainSubstring(`"password":"hunter2"`))
+
+			credentials.Data[corev1.BasicAuthPasswordKey] = []byte("correct-horse")
+			Expect(k8sClient.Update(ctx, credentials)).To(Succeed())
+
+			Eventually(getPullSecretConfig).Should(ContainSubstring(`"password":"correct-horse"`))
+		})
+	})
+
+	When("the lrp takes its registry credentials from a dockerconfigjson secret", func() {
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: lrpNamespace, Name: "pull-secret"},
+				Type:       corev1.SecretTypeDockerConfigJson,
+				Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)},
+			})).To(Succeed())
+
+			lrp.Spec.PrivateRegistry = &eiriniv1.PrivateRegistry{SecretName: "pull-secret"}
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+		})
+
+		It("uses the secret as the pull secret", func() {
+			Eventually(getStatefulSetItems(ctx, lrpNamespace)).Should(HaveLen(1))
+
+			statefulSets, err := getStatefulSetItems(ctx, lrpNamespace)()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(statefulSets[0].Spec.Template.Spec.ImagePullSecrets).To(ContainElement(corev1.LocalObjectReference{Name: "pull-secret"}))
+
+			err = k8sClient.Get(ctx, client.ObjectKey{Namespace: lrpNamespace, Name: lrpName + "-registry"}, &corev1.Secret{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+
+	When("the lrp has ports and routes", func() {
+		BeforeEach(func() {
+			lrp.Spec.Ports = []int32{8080}
+			lrp.Spec.Routes = []eiriniv1.Route{{Hostname: "app.example.com", Port: 8080}}
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+		})
+
+		It("creates a service selecting the lrp instances", func() {
+			service := &corev1.Service{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, client.ObjectKeyFromObject(lrp), service)
+			}).Should(Succeed())
+
+			Expect(service.Spec.Selector).To(HaveKeyWithValue(stset.LabelGUID, lrp.Spec.GUID))
+			Expect(service.Spec.Selector).To(HaveKeyWithValue(stset.LabelVersion, lrp.Spec.Version))
+			Expect(service.Spec.Ports).To(HaveLen(1))
+			Expect(service.Spec.Ports[0].Port).To(Equal(int32(8080)))
+		})
+
+		It("sets the instance port variables and VCAP_APPLICATION on the app container", func() {
+			Eventually(func() ([]corev1.EnvVar, error) {
+				statefulSets, err := getStatefulSetItems(ctx, lrpNamespace)()
+				if err != nil || len(statefulSets) != 1 {
+					return nil, err
+				}
+
+				return statefulSets[0].Spec.Template.Spec.Containers[0].Env, nil
+			}).Should(SatisfyAll(
+				ContainElement(corev1.EnvVar{Name: "CF_INSTANCE_PORT", Value: "8080"}),
+				ContainElement(corev1.EnvVar{Name: "CF_INSTANCE_ADDR", Value: "$(CF_INSTANCE_INTERNAL_IP):8080"}),
+				ContainElement(WithTransform(func(e corev1.EnvVar) string { return e.Name }, Equal("VCAP_APPLICATION"))),
+			))
+		})
+
+		It("puts the route hostnames in VCAP_APPLICATION", func() {
+			Eventually(getVCAPApplication(ctx, lrpNamespace)).Should(SatisfyAll(
+				HaveKeyWithValue("application_uris", ConsistOf("app.example.com")),
+				HaveKeyWithValue("uris", ConsistOf("app.example.com")),
+			))
+		})
+
+		It("updates VCAP_APPLICATION when the routes change", func() {
+			Eventually(func() error {
+				current := &eiriniv1.LRP{}
+				if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(lrp), current); err != nil {
+					return err
+				}
+
+				current.Spec.Routes = append(current.Spec.Routes, eiriniv1.Route{Hostname: "other.example.com", Port: 8080})
+
+				return k8sClient.Update(ctx, current)
+			}).Should(Succeed())
+
+			Eventually(getVCAPApplication(ctx, lrpNamespace)).Should(
+				HaveKeyWithValue("application_uris", ConsistOf("app.example.com", "other.example.com")),
+			)
+		})
+
+		It("creates an ingress for the routes", func() {
+			ingress := &networkingv1.Ingress{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, client.ObjectKeyFromObject(lrp), ingress)
+			}).Should(Succeed())
+
+			Expect(ingress.Spec.Rules).To(HaveLen(1))
+			Expect(ingress.Spec.Rules[0].Host).To(Equal("app.example.com"))
+			Expect(ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Name).To(Equal(lrp.Name))
+		})
+	})
+
+	When("the lrp has no ports and a service of the same name exists", func() {
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Namespace: lrpNamespace, Name: lrpName},
+				Spec: corev1.ServiceSpec{
+					Ports: []corev1.ServicePort{{Port: 80}},
+				},
+			})).To(Succeed())
+
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+		})
+
+		It("leaves the service alone", func() {
+			Eventually(getStatefulSetItems(ctx, lrpNamespace)).Should(HaveLen(1))
+
+			Consistently(func() error {
+				return k8sClient.Get(ctx, client.ObjectKey{Namespace: lrpNamespace, Name: lrpName}, &corev1.Service{})
+			}, "2s").Should(Succeed())
+		})
+	})
+
+	When("the lrp is deleted", func() {
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+			Eventually(getStatefulSetItems(ctx, lrpNamespace)).Should(HaveLen(1))
+			Eventually(func() ([]string, error) {
+				current := &eiriniv1.LRP{}
+				err := k8sClient.Get(ctx, client.ObjectKeyFromObject(lrp), current)
+
+				return current.Finalizers, err
+			}).Should(ContainElement(controllers.LRPCleanupFinalizer))
+
+			Expect(k8sClient.Delete(ctx, lrp)).To(Succeed())
+		})
+
+		It("stops the statefulset before letting the lrp go", func() {
+			Eventually(getStatefulSetItems(ctx, lrpNamespace)).Should(BeEmpty())
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, client.ObjectKeyFromObject(lrp), &eiriniv1.LRP{})
+
+				return apierrors.IsNotFound(err)
+			}).Should(BeTrue())
+		})
+	})
+
+	When("an instance is crashing", func() {
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, lrp)).To(Succeed())
+			Eventually(getStatefulSetItems(ctx, lrpNamespace)).Should(HaveLen(1))
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: lrpNamespace,
+					Name:      lrpName + "-0",
+					Labels: map[string]string{
+						stset.LabelGUID:       lrp.Spec.GUID,
+						stset.LabelVersion:    lrp.Spec.Version,
+						stset.LabelSourceType: stset.AppSourceType,
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: stset.ApplicationContainerName, Image: lrp.Spec.Image}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+
+			pod.Status = corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:         stset.ApplicationContainerName,
+					Image:        lrp.Spec.Image,
+					RestartCount: 3,
+					State: corev1.ContainerState{
+						Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+					},
+					LastTerminationState: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"},
+					},
+				}},
+			}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+		})
+
+		It("reports the instance state in the lrp status", func() {
+			Eventually(func() ([]eiriniv1.InstanceStatus, error) {
+				current := &eiriniv1.LRP{}
+				err := k8sClient.Get(ctx, client.ObjectKeyFromObject(lrp), current)
+
+				return current.Status.Instances, err
+			}).Should(ConsistOf(eiriniv1.InstanceStatus{
+				Index:           0,
+				State:           "CRASHED",
+				RestartCount:    3,
+				LastCrashReason: "Error",
+			}))
+		})
+	})
 })
 
+func getActiveVersion(ctx context.Context, lrp *eiriniv1.LRP) func() (string, error) {
+	return func() (string, error) {
+		current := &eiriniv1.LRP{}
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(lrp), current)
+
+		return current.Status.ActiveVersion, err
+	}
+}
+
+func getServiceSelector(ctx context.Context, lrp *eiriniv1.LRP) func() (map[string]string, error) {
+	return func() (map[string]string, error) {
+		service := &corev1.Service{}
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(lrp), service)
+
+		return service.Spec.Selector, err
+	}
+}
+
+func getVCAPApplication(ctx context.Context, lrpNamespace string) func() (map[string]interface{}, error) {
+	return func() (map[string]interface{}, error) {
+		statefulSets, err := getStatefulSetItems(ctx, lrpNamespace)()
+		if err != nil || len(statefulSets) != 1 {
+			return nil, err
+		}
+
+		vcapApplication := map[string]interface{}{}
+
+		for _, env := range statefulSets[0].Spec.Template.Spec.Containers[0].Env {
+			if env.Name == controllers.EnvVCAPApplication {
+				err = json.Unmarshal([]byte(env.Value), &vcapApplication)
+			}
+		}
+
+		return vcapApplication, err
+	}
+}
+
 func getStatefulSetItems(ctx context.Context, lrpNamespace string) func() ([]appsv1.StatefulSet, error) {
 	return func() ([]appsv1.StatefulSet, error) {
 		statefulsets := appsv1.StatefulSetList{}
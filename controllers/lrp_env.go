@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"code.cloudfoundry.org/eirini"
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/k8s/stset"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	EnvVCAPApplication = "VCAP_APPLICATION"
+
+	defaultFileDescriptorLimit = 16384
+)
+
+type vcapApplication struct {
+	ApplicationID      string     `json:"application_id"`
+	ApplicationName    string     `json:"application_name"`
+	ApplicationURIs    []string   `json:"application_uris"`
+	ApplicationVersion string     `json:"application_version"`
+	Limits             vcapLimits `json:"limits"`
+	Name               string     `json:"name"`
+	ProcessID          string     `json:"process_id"`
+	ProcessType        string     `json:"process_type"`
+	SpaceID            string     `json:"space_id"`
+	SpaceName          string     `json:"space_name"`
+	OrganizationID     string     `json:"organization_id"`
+	OrganizationName   string     `json:"organization_name"`
+	URIs               []string   `json:"uris"`
+	Version            string     `json:"version"`
+}
+
+type vcapLimits struct {
+	Mem  int64 `json:"mem"`
+	Disk int64 `json:"disk"`
+	FDs  int   `json:"fds"`
+}
+
+type instancePort struct {
+	External int32 `json:"external"`
+	Internal int32 `json:"internal"`
+}
+
+// setCFInstanceEnvFn adds the environment Diego used to give app instances
+// that is the same for every instance: the instance ports and address, and
+// VCAP_APPLICATION. Variables already set in the LRP env are left alone.
+// CF_INSTANCE_INDEX depends on the instance and is set by the
+// InstanceIndexInjector pod webhook.
+func setCFInstanceEnvFn(lrp *eiriniv1.LRP) func(interface{}) error {
+	return func(resource interface{}) error {
+		statefulSet, ok := resource.(*appsv1.StatefulSet)
+		if !ok {
+			return fmt.Errorf("failed to cast %v to appsv1.StatefulSet", resource)
+		}
+
+		envs, err := cfInstanceEnv(lrp)
+		if err != nil {
+			return err
+		}
+
+		containers := statefulSet.Spec.Template.Spec.Containers
+		for i := range containers {
+			if containers[i].Name == stset.ApplicationContainerName {
+				containers[i].Env = append(containers[i].Env, envs...)
+			}
+		}
+
+		return nil
+	}
+}
+
+func cfInstanceEnv(lrp *eiriniv1.LRP) ([]corev1.EnvVar, error) {
+	envs := []corev1.EnvVar{}
+
+	addEnv := func(name, value string) {
+		if _, ok := lrp.Spec.Env[name]; !ok {
+			envs = append(envs, corev1.EnvVar{Name: name, Value: value})
+		}
+	}
+
+	if len(lrp.Spec.Ports) > 0 {
+		port := lrp.Spec.Ports[0]
+
+		ports := make([]instancePort, 0, len(lrp.Spec.Ports))
+		for _, p := range lrp.Spec.Ports {
+			ports = append(ports, instancePort{External: p, Internal: p})
+		}
+
+		portsJSON, err := json.Marshal(ports)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal instance ports")
+		}
+
+		addEnv(eirini.EnvCFInstancePort, strconv.Itoa(int(port)))
+		addEnv(eirini.EnvCFInstancePorts, string(portsJSON))
+		addEnv(eirini.EnvCFInstanceAddr, fmt.Sprintf("$(%s):%d", eirini.EnvCFInstanceInternalIP, port))
+	}
+
+	vcapJSON, err := json.Marshal(toVCAPApplication(lrp))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal VCAP_APPLICATION")
+	}
+
+	addEnv(EnvVCAPApplication, string(vcapJSON))
+
+	return envs, nil
+}
+
+// toVCAPApplication takes the application URIs from the hostnames of the LRP
+// routes. VCAP_APPLICATION is part of the pod template, so mapping or
+// unmapping a route rolls the instances.
+func toVCAPApplication(lrp *eiriniv1.LRP) vcapApplication {
+	seen := map[string]bool{}
+	uris := []string{}
+
+	for _, route := range lrp.Spec.Routes {
+		if !seen[route.Hostname] {
+			seen[route.Hostname] = true
+			uris = append(uris, route.Hostname)
+		}
+	}
+
+	return vcapApplication{
+		ApplicationID:      lrp.Spec.AppGUID,
+		ApplicationName:    lrp.Spec.AppName,
+		ApplicationURIs:    uris,
+		ApplicationVersion: lrp.Spec.Version,
+		Limits: vcapLimits{
+			Mem:  lrp.Spec.MemoryMB,
+			Disk: lrp.Spec.DiskMB,
+			FDs:  defaultFileDescriptorLimit,
+		},
+		Name:             lrp.Spec.AppName,
+		ProcessID:        lrp.Spec.GUID,
+		ProcessType:      lrp.Spec.ProcessType,
+		SpaceID:          lrp.Spec.SpaceGUID,
+		SpaceName:        lrp.Spec.SpaceName,
+		OrganizationID:   lrp.Spec.OrgGUID,
+		OrganizationName: lrp.Spec.OrgName,
+		URIs:             uris,
+		Version:          lrp.Spec.Version,
+	}
+}
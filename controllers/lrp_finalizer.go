@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/api"
+	"code.cloudfoundry.org/eirini/k8s/stset"
+	"code.cloudfoundry.org/eirini/k8s/utils"
+	"code.cloudfoundry.org/lager"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	LRPCleanupFinalizer = "eirini.cloudfoundry.org/lrp-cleanup"
+
+	lrpDeletionPollInterval = 2 * time.Second
+
+	eventReasonStopped = "Stopped"
+)
+
+func (r *LRPReconciler) addLRPFinalizer(ctx context.Context, lrp *eiriniv1.LRP) error {
+	if controllerutil.ContainsFinalizer(lrp, LRPCleanupFinalizer) {
+		return nil
+	}
+
+	controllerutil.AddFinalizer(lrp, LRPCleanupFinalizer)
+
+	return errors.Wrap(r.Update(ctx, lrp), "failed to add lrp cleanup finalizer")
+}
+
+// handleDeletedLRP stops all versions of the LRP, waits for their pods to
+// terminate for up to the deletion grace period, and then removes the private
+// registry secrets and pod disruption budgets of its StatefulSets before
+// letting the LRP go.
+func (r *LRPReconciler) handleDeletedLRP(ctx context.Context, logger lager.Logger, lrp *eiriniv1.LRP) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(lrp, LRPCleanupFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	for _, version := range lrpVersions(lrp) {
+		if err := r.WorkloadClient.Stop(ctx, api.LRPIdentifier{GUID: lrp.Spec.GUID, Version: version}); err != nil {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to stop version %s", version)
+		}
+	}
+
+	pods := corev1.PodList{}
+	if err := r.List(ctx, &pods, client.InNamespace(lrp.Namespace), client.MatchingLabels{
+		stset.LabelGUID:       lrp.Spec.GUID,
+		stset.LabelSourceType: stset.AppSourceType,
+	}); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to list lrp pods")
+	}
+
+	if len(pods.Items) > 0 {
+		if time.Since(lrp.DeletionTimestamp.Time) < r.LRPDeletionGracePeriod {
+			return ctrl.Result{RequeueAfter: lrpDeletionPollInterval}, nil
+		}
+
+		logger.Info("pods-still-running-after-grace-period", lager.Data{"pods": len(pods.Items)})
+	}
+
+	if err := r.deleteStatefulSetDependents(ctx, lrp); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	r.Recorder.Eventf(lrp, corev1.EventTypeNormal, eventReasonStopped, "Stopped all instances of LRP %s", lrp.Spec.GUID)
+
+	controllerutil.RemoveFinalizer(lrp, LRPCleanupFinalizer)
+
+	return ctrl.Result{}, errors.Wrap(r.Update(ctx, lrp), "failed to remove lrp cleanup finalizer")
+}
+
+// deleteStatefulSetDependents removes the objects the StatefulSets own, which
+// would otherwise be left to the garbage collector. By now the StatefulSets
+// are gone, so their names are worked out from the LRP versions.
+func (r *LRPReconciler) deleteStatefulSetDependents(ctx context.Context, lrp *eiriniv1.LRP) error {
+	for _, version := range lrpVersions(lrp) {
+		name, err := utils.GetStatefulsetName(&api.LRP{
+			LRPIdentifier: api.LRPIdentifier{GUID: lrp.Spec.GUID, Version: version},
+			AppName:       lrp.Spec.AppName,
+			SpaceName:     lrp.Spec.SpaceName,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to get statefulset name")
+		}
+
+		pdb := &v1beta1.PodDisruptionBudget{ObjectMeta: metav1.ObjectMeta{Namespace: lrp.Namespace, Name: name}}
+		if err := r.Delete(ctx, pdb); client.IgnoreNotFound(err) != nil {
+			return errors.Wrap(err, "failed to delete pod disruption budget")
+		}
+
+		secrets, err := r.listRegistrySecrets(ctx, lrp.Namespace, name)
+		if err != nil {
+			return err
+		}
+
+		for i := range secrets {
+			if err := r.Delete(ctx, &secrets[i]); client.IgnoreNotFound(err) != nil {
+				return errors.Wrap(err, "failed to delete private registry secret")
+			}
+		}
+	}
+
+	return nil
+}
+
+// lrpVersions are all the versions of an LRP that may still have a
+// StatefulSet
+func lrpVersions(lrp *eiriniv1.LRP) []string {
+	seen := map[string]bool{}
+	versions := []string{}
+
+	for _, version := range append([]string{lrp.Spec.Version, lrp.Status.ActiveVersion}, lrp.Status.RetiringVersions...) {
+		if version == "" || seen[version] {
+			continue
+		}
+
+		seen[version] = true
+		versions = append(versions, version)
+	}
+
+	return versions
+}
@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/eirini"
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/api"
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// handleInstanceRestarts runs the instance restart requests in the spec that
+// have not been handled yet and records them in the status, so that they are
+// never replayed. Requests for an instance index the LRP does not have are
+// recorded with an error. Requests that fail otherwise are left unhandled,
+// to be retried on the next reconcile. Handled requests that are no longer in
+// the spec are forgotten.
+func (r *LRPReconciler) handleInstanceRestarts(ctx context.Context, lrp *eiriniv1.LRP, status *eiriniv1.LRPStatus) error {
+	handled := map[string]eiriniv1.InstanceRestartStatus{}
+	for _, restart := range status.HandledInstanceRestarts {
+		handled[restart.ID] = restart
+	}
+
+	status.HandledInstanceRestarts = []eiriniv1.InstanceRestartStatus{}
+
+	var errs *multierror.Error
+
+	for _, request := range lrp.Spec.InstanceRestarts {
+		if restart, ok := handled[request.ID]; ok {
+			status.HandledInstanceRestarts = append(status.HandledInstanceRestarts, restart)
+
+			continue
+		}
+
+		restart := eiriniv1.InstanceRestartStatus{
+			ID:          request.ID,
+			Index:       request.Index,
+			HandledTime: metav1.Now(),
+		}
+
+		err := r.WorkloadClient.StopInstance(ctx, api.LRPIdentifier{GUID: lrp.Spec.GUID, Version: lrp.Spec.Version}, uint(request.Index))
+		if errors.Is(err, eirini.ErrInvalidInstanceIndex) {
+			restart.Error = err.Error()
+		} else if err != nil {
+			errs = multierror.Append(errs, errors.Wrapf(err, "failed to restart instance %d", request.Index))
+
+			continue
+		}
+
+		status.HandledInstanceRestarts = append(status.HandledInstanceRestarts, restart)
+	}
+
+	return errs.ErrorOrNil()
+}
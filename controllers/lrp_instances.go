@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"sort"
+	"strings"
+
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/api"
+	"code.cloudfoundry.org/eirini/k8s/stset"
+	"code.cloudfoundry.org/eirini/k8s/utils"
+	"code.cloudfoundry.org/eirini/util"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const insufficientMemoryMessage = "Insufficient memory"
+
+func applyInstanceStatuses(status *eiriniv1.LRPStatus, pods []corev1.Pod) {
+	status.Instances = nil
+	status.RunningInstances = 0
+	status.StartingInstances = 0
+	status.CrashedInstances = 0
+
+	for i := range pods {
+		instance, ok := toInstanceStatus(pods[i])
+		if !ok {
+			continue
+		}
+
+		switch instance.State {
+		case api.RunningState:
+			status.RunningInstances++
+		case api.PendingState:
+			status.StartingInstances++
+		case api.CrashedState:
+			status.CrashedInstances++
+		}
+
+		status.Instances = append(status.Instances, instance)
+	}
+
+	sort.Slice(status.Instances, func(i, j int) bool {
+		return status.Instances[i].Index < status.Instances[j].Index
+	})
+}
+
+// toInstanceStatus mirrors stset.Getter.GetInstances, but works on cached
+// pods: terminating pods are treated as stopped and the insufficient memory
+// placement error is read from the PodScheduled condition instead of events
+func toInstanceStatus(pod corev1.Pod) (eiriniv1.InstanceStatus, bool) {
+	if pod.DeletionTimestamp != nil {
+		return eiriniv1.InstanceStatus{}, false
+	}
+
+	index, err := util.ParseAppIndex(pod.Name)
+	if err != nil {
+		return eiriniv1.InstanceStatus{}, false
+	}
+
+	instance := eiriniv1.InstanceStatus{
+		Index: index,
+		State: utils.GetPodState(pod),
+		Since: pod.Status.StartTime,
+	}
+
+	if hasInsufficientMemory(pod) {
+		instance.State = api.ErrorState
+		instance.PlacementError = api.InsufficientMemoryError
+	}
+
+	if containerStatus := getAppContainerStatus(pod); containerStatus != nil {
+		instance.RestartCount = containerStatus.RestartCount
+		instance.LastCrashReason = getLastCrashReason(containerStatus)
+	}
+
+	return instance, true
+}
+
+func hasInsufficientMemory(pod corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled &&
+			condition.Status == corev1.ConditionFalse &&
+			strings.Contains(condition.Message, insufficientMemoryMessage) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getAppContainerStatus(pod corev1.Pod) *corev1.ContainerStatus {
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == stset.ApplicationContainerName {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+
+	return nil
+}
+
+func getLastCrashReason(containerStatus *corev1.ContainerStatus) string {
+	if terminated := containerStatus.State.Terminated; terminated != nil {
+		return terminated.Reason
+	}
+
+	if terminated := containerStatus.LastTerminationState.Terminated; terminated != nil {
+		return terminated.Reason
+	}
+
+	if waiting := containerStatus.State.Waiting; waiting != nil && imagePullFailureReasons[waiting.Reason] {
+		return waiting.Reason
+	}
+
+	return ""
+}
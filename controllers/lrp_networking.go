@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/k8s/stset"
+	"code.cloudfoundry.org/lager"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// reconcileService keeps a ClusterIP Service exposing the ports of the LRP,
+// and deletes it when the LRP has no ports. It selects the instances of the
+// given active version, so that traffic only moves to a new version once it
+// is ready.
+func (r *LRPReconciler) reconcileService(ctx context.Context, lrp *eiriniv1.LRP, activeVersion string) error {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: lrp.Namespace,
+			Name:      lrp.Name,
+		},
+	}
+
+	if len(lrp.Spec.Ports) == 0 {
+		err := deleteIfControlled(ctx, r.Client, service, lrp)
+
+		return errors.Wrap(err, "failed to delete service")
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, service, func() error {
+		if err := ensureControlledBy(service, lrp); err != nil {
+			return err
+		}
+
+		service.Labels = map[string]string{
+			stset.LabelGUID:       lrp.Spec.GUID,
+			stset.LabelSourceType: stset.AppSourceType,
+		}
+		service.Spec.Type = corev1.ServiceTypeClusterIP
+		service.Spec.Selector = map[string]string{
+			stset.LabelGUID:       lrp.Spec.GUID,
+			stset.LabelVersion:    activeVersion,
+			stset.LabelSourceType: stset.AppSourceType,
+		}
+		service.Spec.Ports = toServicePorts(lrp.Spec.Ports)
+
+		return ctrl.SetControllerReference(lrp, service, r.Scheme)
+	})
+
+	return errors.Wrap(err, "failed to create or update service")
+}
+
+// reconcileIngress keeps an Ingress with a rule for every route of the LRP,
+// backed by its Service, and deletes it when the LRP has no routes. Routes to
+// ports the LRP does not expose are skipped.
+func (r *LRPReconciler) reconcileIngress(ctx context.Context, lrp *eiriniv1.LRP) error {
+	logger := r.Logger.Session("reconcile-ingress", lager.Data{"guid": lrp.Spec.GUID})
+
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: lrp.Namespace,
+			Name:      lrp.Name,
+		},
+	}
+
+	rules := r.toIngressRules(logger, lrp)
+	if len(rules) == 0 {
+		err := deleteIfControlled(ctx, r.Client, ingress, lrp)
+
+		return errors.Wrap(err, "failed to delete ingress")
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, ingress, func() error {
+		if err := ensureControlledBy(ingress, lrp); err != nil {
+			return err
+		}
+
+		ingress.Labels = map[string]string{
+			stset.LabelGUID:       lrp.Spec.GUID,
+			stset.LabelSourceType: stset.AppSourceType,
+		}
+
+		if r.IngressClassName != "" {
+			ingress.Spec.IngressClassName = &r.IngressClassName
+		}
+
+		ingress.Spec.Rules = rules
+
+		return ctrl.SetControllerReference(lrp, ingress, r.Scheme)
+	})
+
+	return errors.Wrap(err, "failed to create or update ingress")
+}
+
+func (r *LRPReconciler) toIngressRules(logger lager.Logger, lrp *eiriniv1.LRP) []networkingv1.IngressRule {
+	exposed := map[int32]bool{}
+	for _, port := range lrp.Spec.Ports {
+		exposed[port] = true
+	}
+
+	pathType := networkingv1.PathTypePrefix
+	rules := []networkingv1.IngressRule{}
+
+	for _, route := range lrp.Spec.Routes {
+		if !exposed[route.Port] {
+			logger.Info("skipping-route-to-unexposed-port", lager.Data{"hostname": route.Hostname, "port": route.Port})
+
+			continue
+		}
+
+		rules = append(rules, networkingv1.IngressRule{
+			Host: route.Hostname,
+			IngressRuleValue: networkingv1.IngressRuleValue{
+				HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Path:     "/",
+						PathType: &pathType,
+						Backend: networkingv1.IngressBackend{
+							Service: &networkingv1.IngressServiceBackend{
+								Name: lrp.Name,
+								Port: networkingv1.ServiceBackendPort{Number: route.Port},
+							},
+						},
+					}},
+				},
+			},
+		})
+	}
+
+	return rules
+}
+
+func toServicePorts(ports []int32) []corev1.ServicePort {
+	servicePorts := make([]corev1.ServicePort, 0, len(ports))
+
+	for _, port := range ports {
+		servicePorts = append(servicePorts, corev1.ServicePort{
+			Name:       fmt.Sprintf("port-%d", port),
+			Protocol:   corev1.ProtocolTCP,
+			Port:       port,
+			TargetPort: intstr.FromInt(int(port)),
+		})
+	}
+
+	return servicePorts
+}
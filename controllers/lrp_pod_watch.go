@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/k8s/stset"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	lrpIdentifierIndex = "spec.identifier"
+
+	lrpRequeueBaseDelay = 100 * time.Millisecond
+	lrpRequeueMaxDelay  = 5 * time.Minute
+)
+
+// lrpPodPredicate only lets through events for app pods whose status or
+// lifecycle actually changed, so that label or annotation churn does not
+// trigger LRP reconciles
+var lrpPodPredicate = predicate.And(
+	predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetLabels()[stset.LabelSourceType] == stset.AppSourceType
+	}),
+	predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldPod, ok := e.ObjectOld.(*corev1.Pod)
+			if !ok {
+				return false
+			}
+
+			newPod, ok := e.ObjectNew.(*corev1.Pod)
+			if !ok {
+				return false
+			}
+
+			return oldPod.Status.Phase != newPod.Status.Phase ||
+				!reflect.DeepEqual(oldPod.DeletionTimestamp, newPod.DeletionTimestamp) ||
+				!reflect.DeepEqual(oldPod.Status.Conditions, newPod.Status.Conditions) ||
+				!reflect.DeepEqual(oldPod.Status.ContainerStatuses, newPod.Status.ContainerStatuses)
+		},
+	},
+)
+
+func lrpIdentifier(guid, version string) string {
+	return guid + "/" + version
+}
+
+func indexLRPIdentifier(obj client.Object) []string {
+	lrp, ok := obj.(*eiriniv1.LRP)
+	if !ok {
+		return nil
+	}
+
+	return []string{lrpIdentifier(lrp.Spec.GUID, lrp.Spec.Version)}
+}
+
+// podToLRP maps an app pod to the LRP it is an instance of, so that instances
+// crashing, becoming ready or being evicted are reflected in the LRP status.
+func (r *LRPReconciler) podToLRP(obj client.Object) []reconcile.Request {
+	labels := obj.GetLabels()
+
+	guid, version := labels[stset.LabelGUID], labels[stset.LabelVersion]
+	if guid == "" {
+		return nil
+	}
+
+	lrps := eiriniv1.LRPList{}
+
+	err := r.List(context.Background(), &lrps,
+		client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{lrpIdentifierIndex: lrpIdentifier(guid, version)},
+	)
+	if err != nil {
+		r.Logger.Error("failed-to-list-lrps-for-pod", err)
+
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(lrps.Items))
+	for i := range lrps.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&lrps.Items[i])})
+	}
+
+	return requests
+}
+
+// NewLRPRateLimiter limits both how quickly a single LRP is requeued and how
+// many LRP reconciles are queued overall. Pod events are queued through it
+// as well (see rateLimitedHandler), so that crash-looping apps cannot starve
+// the others.
+func NewLRPRateLimiter(qps float64, burst int) ratelimiter.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(lrpRequeueBaseDelay, lrpRequeueMaxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(qps), burst)},
+	)
+}
+
+// rateLimitedHandler queues the requests of the wrapped handler through the
+// rate limiter of the controller, rather than straight away
+type rateLimitedHandler struct {
+	handler.EventHandler
+}
+
+func (h rateLimitedHandler) Create(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Create(e, rateLimitedQueue{q})
+}
+
+func (h rateLimitedHandler) Update(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Update(e, rateLimitedQueue{q})
+}
+
+func (h rateLimitedHandler) Delete(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Delete(e, rateLimitedQueue{q})
+}
+
+func (h rateLimitedHandler) Generic(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Generic(e, rateLimitedQueue{q})
+}
+
+type rateLimitedQueue struct {
+	workqueue.RateLimitingInterface
+}
+
+func (q rateLimitedQueue) Add(item interface{}) {
+	q.AddRateLimited(item)
+}
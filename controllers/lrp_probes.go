@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"fmt"
+
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/k8s/stset"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	defaultLivenessFailureThreshold  = 4
+	defaultReadinessFailureThreshold = 1
+	startupProbePeriodSeconds        = 2
+)
+
+// setProbesFn replaces the probes the vendored converter derives from the
+// health check type, port and endpoint with ones built from the whole
+// Healthcheck, including its timing knobs, start timeout and readiness check
+func setProbesFn(lrp *eiriniv1.LRP) func(interface{}) error {
+	return func(resource interface{}) error {
+		statefulSet, ok := resource.(*appsv1.StatefulSet)
+		if !ok {
+			return fmt.Errorf("failed to cast %v to appsv1.StatefulSet", resource)
+		}
+
+		containers := statefulSet.Spec.Template.Spec.Containers
+		for i := range containers {
+			if containers[i].Name == stset.ApplicationContainerName {
+				setContainerProbes(&containers[i], lrp.Spec.Health)
+			}
+		}
+
+		return nil
+	}
+}
+
+func setContainerProbes(container *corev1.Container, health eiriniv1.Healthcheck) {
+	container.LivenessProbe = livenessProbe(health)
+	container.ReadinessProbe = readinessProbe(health)
+	container.StartupProbe = startupProbe(health)
+}
+
+func livenessProbe(health eiriniv1.Healthcheck) *corev1.Probe {
+	handler := probeHandler(health.Type, health.Port, health.Endpoint)
+	if handler == nil {
+		return nil
+	}
+
+	return &corev1.Probe{
+		Handler:          *handler,
+		TimeoutSeconds:   valueOrDefault(health.InvocationTimeoutSeconds, 0),
+		PeriodSeconds:    valueOrDefault(health.PeriodSeconds, 0),
+		FailureThreshold: valueOrDefault(health.FailureThreshold, defaultLivenessFailureThreshold),
+	}
+}
+
+func readinessProbe(health eiriniv1.Healthcheck) *corev1.Probe {
+	readiness := health.Readiness
+	if readiness == nil {
+		readiness = &eiriniv1.ReadinessCheck{
+			Type:                     health.Type,
+			Port:                     health.Port,
+			Endpoint:                 health.Endpoint,
+			InvocationTimeoutSeconds: health.InvocationTimeoutSeconds,
+			PeriodSeconds:            health.PeriodSeconds,
+		}
+	}
+
+	handler := probeHandler(readiness.Type, readiness.Port, readiness.Endpoint)
+	if handler == nil {
+		return nil
+	}
+
+	return &corev1.Probe{
+		Handler:          *handler,
+		TimeoutSeconds:   valueOrDefault(readiness.InvocationTimeoutSeconds, 0),
+		PeriodSeconds:    valueOrDefault(readiness.PeriodSeconds, 0),
+		FailureThreshold: valueOrDefault(readiness.FailureThreshold, defaultReadinessFailureThreshold),
+	}
+}
+
+// startupProbe gives the app its start timeout to pass the liveness check
+// for the first time, checking every couple of seconds so that apps that
+// start quickly get traffic quickly
+func startupProbe(health eiriniv1.Healthcheck) *corev1.Probe {
+	handler := probeHandler(health.Type, health.Port, health.Endpoint)
+	if handler == nil || health.TimeoutMs == 0 {
+		return nil
+	}
+
+	startTimeoutSeconds := int32((health.TimeoutMs + 999) / 1000) //nolint:gomnd
+	failureThreshold := (startTimeoutSeconds + startupProbePeriodSeconds - 1) / startupProbePeriodSeconds
+
+	return &corev1.Probe{
+		Handler:          *handler,
+		TimeoutSeconds:   valueOrDefault(health.InvocationTimeoutSeconds, 0),
+		PeriodSeconds:    startupProbePeriodSeconds,
+		FailureThreshold: failureThreshold,
+	}
+}
+
+func probeHandler(checkType string, port int32, endpoint string) *corev1.Handler {
+	switch checkType {
+	case eiriniv1.HealthcheckTypeHTTP:
+		return &corev1.Handler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path: endpoint,
+				Port: intstr.FromInt(int(port)),
+			},
+		}
+	case eiriniv1.HealthcheckTypePort:
+		return &corev1.Handler{
+			TCPSocket: &corev1.TCPSocketAction{
+				Port: intstr.FromInt(int(port)),
+			},
+		}
+	default:
+		return nil
+	}
+}
+
+func valueOrDefault(value *int32, defaultValue int32) int32 {
+	if value == nil {
+		return defaultValue
+	}
+
+	return *value
+}
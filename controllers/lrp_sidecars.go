@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"fmt"
+
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/k8s/shared"
+	"code.cloudfoundry.org/eirini/k8s/stset"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// setSidecarsFn replaces the sidecar containers generated by the vendored
+// converter, which all run the app image with the app CPU and disk, with
+// containers built from the whole Sidecar spec. Sidecars that start before
+// the app are placed ahead of it. The kubelet starts containers in order but
+// does not wait for them to be ready, so this ordering is best-effort.
+func setSidecarsFn(lrp *eiriniv1.LRP) func(interface{}) error {
+	return func(resource interface{}) error {
+		statefulSet, ok := resource.(*appsv1.StatefulSet)
+		if !ok {
+			return fmt.Errorf("failed to cast %v to appsv1.StatefulSet", resource)
+		}
+
+		before := []corev1.Container{}
+		after := []corev1.Container{}
+
+		for _, sidecar := range lrp.Spec.Sidecars {
+			container := toSidecarContainer(lrp, sidecar)
+
+			if sidecar.Start == eiriniv1.SidecarStartBeforeApp {
+				before = append(before, container)
+			} else {
+				after = append(after, container)
+			}
+		}
+
+		containers := before
+
+		for _, container := range statefulSet.Spec.Template.Spec.Containers {
+			if container.Name == stset.ApplicationContainerName {
+				containers = append(containers, container)
+			}
+		}
+
+		statefulSet.Spec.Template.Spec.Containers = append(containers, after...)
+
+		return nil
+	}
+}
+
+func toSidecarContainer(lrp *eiriniv1.LRP, sidecar eiriniv1.Sidecar) corev1.Container {
+	image := sidecar.Image
+	if image == "" {
+		image = lrp.Spec.Image
+	}
+
+	cpuWeight := lrp.Spec.CPUWeight
+	if sidecar.CPUWeight != nil {
+		cpuWeight = *sidecar.CPUWeight
+	}
+
+	diskMB := lrp.Spec.DiskMB
+	if sidecar.DiskMB != nil {
+		diskMB = *sidecar.DiskMB
+	}
+
+	ports := []corev1.ContainerPort{}
+	for _, port := range sidecar.Ports {
+		ports = append(ports, corev1.ContainerPort{ContainerPort: port})
+	}
+
+	container := corev1.Container{
+		Name:      sidecar.Name,
+		Image:     image,
+		Command:   sidecar.Command,
+		Env:       shared.MapToEnvVar(sidecar.Env),
+		Ports:     ports,
+		Resources: containerResources(cpuWeight, sidecar.MemoryMB, diskMB),
+	}
+
+	if sidecar.Health != nil {
+		setContainerProbes(&container, *sidecar.Health)
+	}
+
+	return container
+}
+
+// containerResources mirrors the resources the vendored converter gives
+// containers: memory is both requested and limited, CPU is requested in
+// millicores of the CPU weight and disk limits ephemeral storage
+func containerResources(cpuWeight uint8, memoryMB, diskMB int64) corev1.ResourceRequirements {
+	memory := *resource.NewScaledQuantity(memoryMB, resource.Mega)
+	cpu := *resource.NewScaledQuantity(int64(cpuWeight), resource.Milli)
+	ephemeralStorage := *resource.NewScaledQuantity(diskMB, resource.Mega)
+
+	return corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{
+			corev1.ResourceMemory:           memory,
+			corev1.ResourceEphemeralStorage: ephemeralStorage,
+		},
+		Requests: corev1.ResourceList{
+			corev1.ResourceMemory: memory,
+			corev1.ResourceCPU:    cpu,
+		},
+	}
+}
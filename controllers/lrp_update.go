@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/api"
+	"code.cloudfoundry.org/eirini/k8s/shared"
+	"code.cloudfoundry.org/eirini/k8s/stset"
+	"code.cloudfoundry.org/eirini/util"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AnnotationTemplateHash records a hash of the pod template the controller
+// last generated for a StatefulSet, so that the template is only patched
+// when the LRP spec actually changes.
+const AnnotationTemplateHash = "eirini.cloudfoundry.org/template-hash"
+
+// lrpSecrets are what the StatefulSet options need to know about the
+// Secrets the controller keeps for an LRP
+type lrpSecrets struct {
+	envHash         string
+	imagePullSecret string
+}
+
+func (r *LRPReconciler) reconcileSecrets(ctx context.Context, lrp *eiriniv1.LRP) (lrpSecrets, error) {
+	envHash, err := r.reconcileEnvSecret(ctx, lrp)
+	if err != nil {
+		return lrpSecrets{}, errors.Wrap(err, "failed to reconcile env secret")
+	}
+
+	imagePullSecret, err := r.reconcileRegistrySecret(ctx, lrp)
+	if err != nil {
+		return lrpSecrets{}, errors.Wrap(err, "failed to reconcile registry secret")
+	}
+
+	return lrpSecrets{envHash: envHash, imagePullSecret: imagePullSecret}, nil
+}
+
+// statefulSetOptions are applied to the StatefulSet generated from an LRP
+// both when it is first desired and when it is updated. The template hash
+// must be the last one, so that it covers the changes made by the others.
+func (r *LRPReconciler) statefulSetOptions(lrp *eiriniv1.LRP, secrets lrpSecrets) []shared.Option {
+	return []shared.Option{
+		r.setOwnerFn(lrp),
+		setLRPVolumesFn(lrp),
+		setCFInstanceEnvFn(lrp),
+		setSecretEnvFn(lrp, secrets.envHash),
+		setImagePullSecretFn(secrets.imagePullSecret),
+		setProbesFn(lrp),
+		setSidecarsFn(lrp),
+		setTemplateHash,
+	}
+}
+
+// updateStatefulSet regenerates the StatefulSet from the whole LRP spec and
+// patches the pod template, labels and annotations when they differ from
+// what was last applied. The selector, service name and pod management
+// policy are immutable and are left alone: they only depend on the LRP
+// GUID and version, and changing either of those results in a new
+// StatefulSet rather than an update. Pull secrets generated by the vendored
+// desirer are deleted when a patch stops the template using them. Any left
+// behind by a failed delete go with the StatefulSet, which owns them.
+func (r *LRPReconciler) updateStatefulSet(ctx context.Context, lrp *eiriniv1.LRP, appLRP *api.LRP, secrets lrpSecrets) error {
+	statefulSet, err := r.getStatefulSet(ctx, lrp)
+	if err != nil {
+		return err
+	}
+
+	if statefulSet == nil {
+		return nil
+	}
+
+	desired, err := r.StatefulSetConverter.Convert(statefulSet.Name, appLRP, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to convert lrp to statefulset")
+	}
+
+	desired.Namespace = statefulSet.Namespace
+
+	if lastUpdated, ok := statefulSet.Spec.Template.Annotations[stset.AnnotationLastUpdated]; ok {
+		desired.Spec.Template.Annotations[stset.AnnotationLastUpdated] = lastUpdated
+	}
+
+	if err = shared.ApplyOpts(desired, r.statefulSetOptions(lrp, secrets)...); err != nil {
+		return errors.Wrap(err, "failed to apply statefulset options")
+	}
+
+	if statefulSet.Annotations[AnnotationTemplateHash] != desired.Annotations[AnnotationTemplateHash] {
+		updated := statefulSet.DeepCopy()
+		updated.Labels = desired.Labels
+		updated.Annotations = desired.Annotations
+		updated.Spec.Replicas = desired.Spec.Replicas
+		updated.Spec.Template = desired.Spec.Template
+
+		if err = r.Patch(ctx, updated, client.MergeFrom(statefulSet)); err != nil {
+			return errors.Wrap(err, "failed to patch statefulset")
+		}
+
+		return r.deleteStaleRegistrySecrets(ctx, updated)
+	}
+
+	return nil
+}
+
+// setTemplateHash ignores the last updated annotation, as Cloud Controller
+// bumps it on every change, including scaling, which must not restart the
+// instances. It also ignores the order of env vars, as they are generated
+// from maps.
+func setTemplateHash(resource interface{}) error {
+	statefulSet, ok := resource.(*appsv1.StatefulSet)
+	if !ok {
+		return fmt.Errorf("failed to cast %v to appsv1.StatefulSet", resource)
+	}
+
+	template := statefulSet.Spec.Template.DeepCopy()
+	delete(template.Annotations, stset.AnnotationLastUpdated)
+
+	for i := range template.Spec.Containers {
+		env := template.Spec.Containers[i].Env
+		sort.SliceStable(env, func(a, b int) bool { return env[a].Name < env[b].Name })
+	}
+
+	templateJSON, err := json.Marshal(template)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal pod template")
+	}
+
+	hash, err := util.Hash(string(templateJSON))
+	if err != nil {
+		return errors.Wrap(err, "failed to hash pod template")
+	}
+
+	if statefulSet.Annotations == nil {
+		statefulSet.Annotations = map[string]string{}
+	}
+
+	statefulSet.Annotations[AnnotationTemplateHash] = hash
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/api"
+	"code.cloudfoundry.org/eirini/k8s/stset"
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	versionTransitionPollInterval = 30 * time.Second
+
+	reasonVersionRolledBack = "VersionRolledBack"
+	reasonVersionActive     = "VersionActive"
+)
+
+// transitionVersions moves the active version to the spec version once its
+// StatefulSet has enough ready instances, and stops the StatefulSets of all
+// the other versions of the LRP. Until then the previously active version
+// keeps running. Setting the spec version back to the active version rolls
+// the transition back; so does the transition timing out, in which case the
+// new version is recorded as failed. The Service is pointed at the active
+// version before the other versions are stopped, so that no traffic is
+// dropped while the version changes.
+func (r *LRPReconciler) transitionVersions(ctx context.Context, lrp *eiriniv1.LRP, status *eiriniv1.LRPStatus, statefulSet *appsv1.StatefulSet) error {
+	desiredVersion := lrp.Spec.Version
+
+	switch {
+	case status.ActiveVersion == "" || status.ActiveVersion == desiredVersion:
+		status.ActiveVersion = desiredVersion
+		status.FailedVersion = ""
+		status.VersionTransitionStartTime = nil
+	case status.FailedVersion == desiredVersion:
+		// rolled back, the active version keeps running
+	case r.versionIsReady(lrp, statefulSet):
+		status.PreviousVersion = status.ActiveVersion
+		status.ActiveVersion = desiredVersion
+		status.VersionTransitionStartTime = nil
+	case status.VersionTransitionStartTime == nil:
+		now := metav1.Now()
+		status.VersionTransitionStartTime = &now
+	case r.VersionTransitionTimeout > 0 && time.Since(status.VersionTransitionStartTime.Time) > r.VersionTransitionTimeout:
+		status.FailedVersion = desiredVersion
+		status.VersionTransitionStartTime = nil
+	}
+
+	setCondition(&status.Conditions, lrp.Generation, eiriniv1.LRPConditionRolledBack, status.FailedVersion != "",
+		reasonVersionRolledBack, reasonVersionActive, rolledBackMessage(status))
+
+	if err := r.reconcileService(ctx, lrp, status.ActiveVersion); err != nil {
+		return errors.Wrap(err, "failed to reconcile service")
+	}
+
+	retiring, err := r.retireVersions(ctx, lrp, status)
+	status.RetiringVersions = retiring
+
+	return err
+}
+
+// updateRolledBackStatus keeps the retiring versions up to date once the
+// spec version has been rolled back, as it is no longer desired or updated
+func (r *LRPReconciler) updateRolledBackStatus(ctx context.Context, lrp *eiriniv1.LRP) error {
+	newStatus := lrp.Status.DeepCopy()
+	newStatus.ObservedGeneration = lrp.Generation
+
+	var errs *multierror.Error
+
+	err := r.transitionVersions(ctx, lrp, newStatus, nil)
+	errs = multierror.Append(errs, errors.Wrap(err, "failed to transition versions"))
+
+	err = r.UpdateLRPStatus(ctx, lrp, *newStatus)
+	errs = multierror.Append(errs, errors.Wrap(err, "failed to update lrp status"))
+
+	return errs.ErrorOrNil()
+}
+
+func (r *LRPReconciler) retireVersions(ctx context.Context, lrp *eiriniv1.LRP, status *eiriniv1.LRPStatus) ([]string, error) {
+	statefulSets := appsv1.StatefulSetList{}
+
+	err := r.List(ctx, &statefulSets, client.InNamespace(lrp.Namespace), client.MatchingLabels{
+		stset.LabelGUID:       lrp.Spec.GUID,
+		stset.LabelSourceType: stset.AppSourceType,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list lrp statefulsets")
+	}
+
+	retiring := []string{}
+
+	for _, statefulSet := range statefulSets.Items {
+		version := statefulSet.Labels[stset.LabelVersion]
+		if version == status.ActiveVersion || (version == lrp.Spec.Version && version != status.FailedVersion) {
+			continue
+		}
+
+		retiring = append(retiring, version)
+
+		if statefulSet.DeletionTimestamp != nil {
+			continue
+		}
+
+		if err := r.WorkloadClient.Stop(ctx, api.LRPIdentifier{GUID: lrp.Spec.GUID, Version: version}); err != nil {
+			return retiring, errors.Wrapf(err, "failed to stop version %s", version)
+		}
+	}
+
+	return retiring, nil
+}
+
+func (r *LRPReconciler) versionIsReady(lrp *eiriniv1.LRP, statefulSet *appsv1.StatefulSet) bool {
+	if statefulSet == nil {
+		return false
+	}
+
+	threshold := r.VersionReadyThreshold
+	if threshold <= 0 || threshold > 100 {
+		threshold = 100
+	}
+
+	required := int32(math.Ceil(float64(lrp.Spec.Instances) * float64(threshold) / 100))
+
+	return statefulSet.Status.ReadyReplicas >= required
+}
+
+func versionTransitionInProgress(lrp *eiriniv1.LRP) bool {
+	return lrp.Status.ActiveVersion != lrp.Spec.Version && lrp.Status.FailedVersion != lrp.Spec.Version
+}
+
+func rolledBackMessage(status *eiriniv1.LRPStatus) string {
+	if status.FailedVersion == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("version %s did not become ready in time, version %s is still active", status.FailedVersion, status.ActiveVersion)
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"context"
+
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/k8s/stset"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func lrpLabels(lrp *eiriniv1.LRP) client.MatchingLabels {
+	return client.MatchingLabels{
+		stset.LabelGUID:       lrp.Spec.GUID,
+		stset.LabelVersion:    lrp.Spec.Version,
+		stset.LabelSourceType: stset.AppSourceType,
+	}
+}
+
+func (r *LRPReconciler) getStatefulSet(ctx context.Context, lrp *eiriniv1.LRP) (*appsv1.StatefulSet, error) {
+	statefulSetList := appsv1.StatefulSetList{}
+
+	if err := r.List(ctx, &statefulSetList, client.InNamespace(lrp.Namespace), lrpLabels(lrp)); err != nil {
+		return nil, errors.Wrap(err, "failed to list lrp statefulsets")
+	}
+
+	if len(statefulSetList.Items) == 0 {
+		return nil, nil
+	}
+
+	return &statefulSetList.Items[0], nil
+}
+
+func (r *LRPReconciler) getPods(ctx context.Context, lrp *eiriniv1.LRP) ([]corev1.Pod, error) {
+	podList := corev1.PodList{}
+
+	if err := r.List(ctx, &podList, client.InNamespace(lrp.Namespace), lrpLabels(lrp)); err != nil {
+		return nil, errors.Wrap(err, "failed to list lrp pods")
+	}
+
+	return podList.Items, nil
+}
@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	TaskDeletions     = "eirini_task_deletions"
+	TaskDeletionsHelp = "The total number of completed tasks deleted after their TTL expired"
+)
+
+var taskDeletions = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: TaskDeletions,
+	Help: TaskDeletionsHelp,
+})
+
+func init() {
+	metrics.Registry.MustRegister(taskDeletions)
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// apiReadClient reads from the API server and writes through the wrapped
+// client. The cache only holds the metadata of Secrets, so that the manager
+// does not keep every Secret in the cluster in memory, and reading a full
+// Secret through the manager client would start an informer for them.
+type apiReadClient struct {
+	client.Client
+	reader client.Reader
+}
+
+func (c apiReadClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	return c.reader.Get(ctx, key, obj)
+}
+
+func (c apiReadClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return c.reader.List(ctx, list, opts...)
+}
+
+// deleteIfControlled deletes obj when it exists and is controlled by owner.
+// Objects of the same name that belong to someone else are left alone. The
+// lookup goes through the cache, so that reconciling an owner that does not
+// need obj does not cost an API call.
+func deleteIfControlled(ctx context.Context, c client.Client, obj client.Object, owner metav1.Object) error {
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	if !metav1.IsControlledBy(obj, owner) {
+		return nil
+	}
+
+	return client.IgnoreNotFound(c.Delete(ctx, obj))
+}
+
+// secretMetadata is a Secret with only its metadata, which the manager client
+// reads from the metadata-only Secret cache the LRPReconciler watches through
+func secretMetadata(namespace, name string) *metav1.PartialObjectMetadata {
+	secret := &metav1.PartialObjectMetadata{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+	}
+	secret.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+
+	return secret
+}
+
+func secretMetadataList() *metav1.PartialObjectMetadataList {
+	secrets := &metav1.PartialObjectMetadataList{}
+	secrets.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("SecretList"))
+
+	return secrets
+}
+
+// ensureControlledBy fails for an existing obj that is not controlled by
+// owner, so that CreateOrUpdate does not adopt and overwrite it
+func ensureControlledBy(obj, owner metav1.Object) error {
+	if obj.GetUID() == "" || metav1.IsControlledBy(obj, owner) {
+		return nil
+	}
+
+	return notControlledError{name: obj.GetName(), owner: owner.GetName()}
+}
+
+// notControlledError is returned for an object that already exists and is
+// controlled by someone else
+type notControlledError struct {
+	name  string
+	owner string
+}
+
+func (e notControlledError) Error() string {
+	return fmt.Sprintf("%s already exists and is not controlled by %s", e.name, e.owner)
+}
+
+func isNotControlled(err error) bool {
+	return errors.As(err, &notControlledError{})
+}
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/k8s/stset"
+	"code.cloudfoundry.org/eirini/k8s/utils/dockerutils"
+	"code.cloudfoundry.org/eirini/util"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// registrySecretName is the name of the pull secret the controller generates
+// for an LRP or Task whose registry credentials are not a dockerconfigjson
+// Secret already
+func registrySecretName(ownerName string) string {
+	return ownerName + "-registry"
+}
+
+// registryDockerConfig works out how to pull from a private registry. A
+// referenced dockerconfigjson Secret is returned by name, to be used as the
+// pull secret as is. Inline credentials and basic-auth Secrets are turned
+// into the dockerconfigjson of a pull secret that has to be generated.
+func registryDockerConfig(ctx context.Context, reader client.Reader, namespace, image string, registry *eiriniv1.PrivateRegistry) (string, string, error) {
+	username, password := registry.Username, registry.Password
+
+	if registry.SecretName != "" {
+		secret := &corev1.Secret{}
+
+		err := reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: registry.SecretName}, secret)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "failed to get registry secret %s", registry.SecretName)
+		}
+
+		switch secret.Type {
+		case corev1.SecretTypeDockerConfigJson:
+			return secret.Name, "", nil
+		case corev1.SecretTypeBasicAuth:
+			username = string(secret.Data[corev1.BasicAuthUsernameKey])
+			password = string(secret.Data[corev1.BasicAuthPasswordKey])
+		default:
+			return "", "", fmt.Errorf("registry secret %s has unsupported type %q", secret.Name, secret.Type)
+		}
+	}
+
+	dockerConfigJSON, err := dockerutils.NewDockerConfig(util.ParseImageRegistryHost(image), username, password).JSON()
+
+	return "", dockerConfigJSON, errors.Wrap(err, "failed to generate docker config")
+}
+
+// reconcileRegistrySecret keeps the generated pull secret of an LRP in line
+// with its registry credentials, and deletes it when it is not needed.
+// Secrets of the same name that the LRP does not control are never changed.
+// It returns the name of the pull secret the instances should use, if any.
+func (r *LRPReconciler) reconcileRegistrySecret(ctx context.Context, lrp *eiriniv1.LRP) (string, error) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: lrp.Namespace,
+			Name:      registrySecretName(lrp.Name),
+		},
+	}
+
+	existing, dockerConfigJSON := "", ""
+
+	if lrp.Spec.PrivateRegistry != nil {
+		var err error
+
+		existing, dockerConfigJSON, err = registryDockerConfig(ctx, r.APIReader, lrp.Namespace, lrp.Spec.Image, lrp.Spec.PrivateRegistry)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if dockerConfigJSON == "" {
+		if err := deleteIfControlled(ctx, r.Client, secretMetadata(secret.Namespace, secret.Name), lrp); err != nil {
+			return "", errors.Wrap(err, "failed to delete registry secret")
+		}
+
+		return existing, nil
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, apiReadClient{r.Client, r.APIReader}, secret, func() error {
+		if err := ensureControlledBy(secret, lrp); err != nil {
+			return err
+		}
+
+		secret.Labels = map[string]string{
+			stset.LabelGUID:       lrp.Spec.GUID,
+			stset.LabelSourceType: stset.AppSourceType,
+		}
+		secret.Type = corev1.SecretTypeDockerConfigJson
+		secret.Data = map[string][]byte{dockerutils.DockerConfigKey: []byte(dockerConfigJSON)}
+
+		return ctrl.SetControllerReference(lrp, secret, r.Scheme)
+	})
+
+	return secret.Name, errors.Wrap(err, "failed to create or update registry secret")
+}
+
+// reconcileRegistrySecret creates the pull secret of a Task that takes its
+// registry credentials from a Secret. Tasks with inline credentials are
+// left to the vendored desirer. Like the env Secret, an existing pull secret
+// of the same name is only used when the Task controls it.
+func (r *TaskReconciler) reconcileRegistrySecret(ctx context.Context, task *eiriniv1.Task) (string, error) {
+	if task.Spec.PrivateRegistry == nil || task.Spec.PrivateRegistry.SecretName == "" {
+		return "", nil
+	}
+
+	existing, dockerConfigJSON, err := registryDockerConfig(ctx, r.APIReader, task.Namespace, task.Spec.Image, task.Spec.PrivateRegistry)
+	if err != nil || existing != "" {
+		return existing, err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: task.Namespace,
+			Name:      registrySecretName(task.Name),
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{dockerutils.DockerConfigKey: []byte(dockerConfigJSON)},
+	}
+
+	if err = ctrl.SetControllerReference(task, secret, r.Scheme); err != nil {
+		return "", errors.Wrap(err, "failed to set controller reference")
+	}
+
+	err = r.Create(ctx, secret)
+	if apierrors.IsAlreadyExists(err) {
+		return secret.Name, r.ensureSecretControlled(ctx, task, secret.Name)
+	}
+
+	return secret.Name, errors.Wrap(err, "failed to create registry secret")
+}
+
+// setImagePullSecretFn adds the pull secret of a private registry to the pod
+// template of a StatefulSet or Job
+func setImagePullSecretFn(name string) func(interface{}) error {
+	return func(resource interface{}) error {
+		var podSpec *corev1.PodSpec
+
+		switch obj := resource.(type) {
+		case *appsv1.StatefulSet:
+			podSpec = &obj.Spec.Template.Spec
+		case *batchv1.Job:
+			podSpec = &obj.Spec.Template.Spec
+		default:
+			return fmt.Errorf("failed to cast %v to appsv1.StatefulSet or batchv1.Job", resource)
+		}
+
+		if name != "" {
+			podSpec.ImagePullSecrets = append(podSpec.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+		}
+
+		return nil
+	}
+}
+
+// registrySecretOwnerIndex indexes the pull secrets the vendored desirer
+// generated by the StatefulSets that own them. Those Secrets carry no labels
+// to select them by, so the index is what keeps looking them up from going
+// through every Secret in the namespace.
+const registrySecretOwnerIndex = "metadata.ownerReferences.statefulSet"
+
+func indexRegistrySecretOwners(obj client.Object) []string {
+	if obj.GetGenerateName() != stset.PrivateRegistrySecretGenerateName {
+		return nil
+	}
+
+	names := []string{}
+
+	for _, owner := range obj.GetOwnerReferences() {
+		if owner.Kind == "StatefulSet" {
+			names = append(names, owner.Name)
+		}
+	}
+
+	return names
+}
+
+// listRegistrySecrets lists the metadata of the pull secrets the vendored
+// desirer generated for a StatefulSet
+func (r *LRPReconciler) listRegistrySecrets(ctx context.Context, namespace, statefulSetName string) ([]metav1.PartialObjectMetadata, error) {
+	secrets := secretMetadataList()
+
+	err := r.List(ctx, secrets,
+		client.InNamespace(namespace),
+		client.MatchingFields{registrySecretOwnerIndex: statefulSetName},
+	)
+
+	return secrets.Items, errors.Wrap(err, "failed to list registry secrets")
+}
+
+// deleteStaleRegistrySecrets deletes the pull secrets the vendored desirer
+// generated for a StatefulSet once its template no longer uses them, as the
+// controller now manages the pull secrets itself
+func (r *LRPReconciler) deleteStaleRegistrySecrets(ctx context.Context, statefulSet *appsv1.StatefulSet) error {
+	inUse := map[string]bool{}
+	for _, ref := range statefulSet.Spec.Template.Spec.ImagePullSecrets {
+		inUse[ref.Name] = true
+	}
+
+	secrets, err := r.listRegistrySecrets(ctx, statefulSet.Namespace, statefulSet.Name)
+	if err != nil {
+		return err
+	}
+
+	for i := range secrets {
+		if inUse[secrets[i].Name] {
+			continue
+		}
+
+		if err := r.Delete(ctx, &secrets[i]); client.IgnoreNotFound(err) != nil {
+			return errors.Wrap(err, "failed to delete stale registry secret")
+		}
+	}
+
+	return nil
+}
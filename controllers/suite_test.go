@@ -102,10 +102,31 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 
 	err = (&controllers.LRPReconciler{
-		Logger:         lagertest.NewTestLogger("eirini-controller-test"),
-		Client:         k8sManager.GetClient(),
-		Scheme:         k8sManager.GetScheme(),
-		WorkloadClient: lrpWorkloadsClient,
+		Logger:               lagertest.NewTestLogger("eirini-controller-test"),
+		Client:               k8sManager.GetClient(),
+		APIReader:            k8sManager.GetAPIReader(),
+		Scheme:               k8sManager.GetScheme(),
+		WorkloadClient:       lrpWorkloadsClient,
+		StatefulSetConverter: controllers.CreateLRPToStatefulSetConverter(eirini.ControllerConfig{}, 0),
+		Recorder:             k8sManager.GetEventRecorderFor("lrp-controller"),
+	}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	taskWorkloadsClient := controllers.CreateTaskWorkloadsClient(
+		controllers.NewLagrLogger(log.FromContext(context.Background())),
+		clientset,
+		eirini.ControllerConfig{},
+		0,
+	)
+
+	err = (&controllers.TaskReconciler{
+		Logger:                       lagertest.NewTestLogger("eirini-controller-test"),
+		Client:                       k8sManager.GetClient(),
+		APIReader:                    k8sManager.GetAPIReader(),
+		Scheme:                       k8sManager.GetScheme(),
+		WorkloadClient:               taskWorkloadsClient,
+		CallbackClient:               controllers.CreateCompletionCallbackClient(true, ""),
+		CompletionCallbackRetryLimit: 3,
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
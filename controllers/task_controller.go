@@ -18,45 +18,337 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	"code.cloudfoundry.org/eirini"
 	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/api"
+	"code.cloudfoundry.org/eirini/k8s/reconciler"
+	workloadsv1 "code.cloudfoundry.org/eirini/pkg/apis/eirini/v1"
+	"code.cloudfoundry.org/eirini/util"
+	"code.cloudfoundry.org/lager"
 )
 
 // TaskReconciler reconciles a Task object
 type TaskReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	// APIReader reads the full Secrets the cache only holds the metadata of
+	APIReader      client.Reader
+	Logger         lager.Logger
+	Scheme         *runtime.Scheme
+	WorkloadClient reconciler.TaskWorkloadClient
+	// TaskTTLSeconds is how long a completed Task is kept around before it
+	// is deleted, unless the Task overrides it in its spec.
+	TaskTTLSeconds int
+	CallbackClient JSONClient
+	// CompletionCallbackRetryLimit is how many times a failed completion
+	// callback is retried before the controller gives up on it.
+	CompletionCallbackRetryLimit int
+	// DefaultTaskTimeoutSeconds and DefaultTaskMaxRetries apply to Tasks that
+	// do not set a timeout or a retry limit. A timeout of 0 means no timeout.
+	DefaultTaskTimeoutSeconds int64
+	DefaultTaskMaxRetries     int32
 }
 
 //+kubebuilder:rbac:groups=eirini.cloudfoundry.org,resources=tasks,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=eirini.cloudfoundry.org,resources=tasks/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=eirini.cloudfoundry.org,resources=tasks/finalizers,verbs=update
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;watch;list
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=create;update;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;delete;patch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the Task object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// Reconcile desires a Job for every Task and mirrors the Job's progress back
+// into the Task status. Cancelled Tasks have their Job deleted. Once a Task
+// completes its completion callback is delivered, and the Task is deleted,
+// together with the resources it owns, when its TTL expires.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.8.3/pkg/reconcile
 func (r *TaskReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+	logger := r.Logger.Session(
+		"reconcile-task",
+		lager.Data{
+			"name":      req.NamespacedName.Name,
+			"namespace": req.NamespacedName.Namespace,
+		},
+	)
 
-	// your logic here
+	task := eiriniv1.Task{}
+	if err := r.Get(ctx, req.NamespacedName, &task); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	result, err := r.do(ctx, logger, &task)
+	if err != nil {
+		logger.Error("failed-to-reconcile", err)
+
+		return reconcile.Result{}, err
+	}
+
+	return result, nil
+}
+
+func (r *TaskReconciler) do(ctx context.Context, logger lager.Logger, task *eiriniv1.Task) (ctrl.Result, error) {
+	if !task.DeletionTimestamp.IsZero() {
+		return r.handleDeletedTask(ctx, logger, task)
+	}
+
+	if err := r.addCallbackFinalizer(ctx, task); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if taskHasCompleted(task.Status) {
+		return r.handleCompletedTask(ctx, logger, task)
+	}
+
+	if task.Spec.Cancelled {
+		return ctrl.Result{}, r.cancelTask(ctx, logger, task)
+	}
+
+	status, err := r.WorkloadClient.GetStatus(ctx, task.Spec.GUID)
+	if errors.Is(err, eirini.ErrNotFound) {
+		imagePullSecret, err := r.reconcileSecrets(ctx, task)
+		if isNotControlled(err) {
+			return ctrl.Result{}, r.failTask(ctx, logger, task, err.Error())
+		}
+
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		err = r.WorkloadClient.Desire(ctx, task.Namespace, toAPITask(task),
+			r.setOwnerFn(task), r.setExecutionPolicyFn(task), setTaskVolumesFn(task), setTaskSecretEnvFn(task),
+			setImagePullSecretFn(imagePullSecret))
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return ctrl.Result{}, errors.Wrap(err, "failed to desire task")
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to get task status")
+	}
+
+	job, err := r.getTaskJob(ctx, task)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to get task job")
+	}
+
+	pod, err := r.getTaskPod(ctx, task)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to get task pod")
+	}
+
+	newStatus := task.Status.DeepCopy()
+	applyJobStatus(newStatus, status)
+	applyPodStatus(newStatus, pod)
+	applyJobConditions(newStatus, job)
+	newStatus.ObservedGeneration = task.Generation
+	setTaskConditions(newStatus, task.Generation, pod)
+
+	if err = r.UpdateTaskStatus(ctx, task, *newStatus); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to update task status")
+	}
+
+	if taskHasCompleted(*newStatus) && !callbackPending(task) {
+		return ctrl.Result{RequeueAfter: r.ttl(task)}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// reconcileSecrets creates the Secrets the Job of a Task uses, and returns
+// the name of its pull secret, if any
+func (r *TaskReconciler) reconcileSecrets(ctx context.Context, task *eiriniv1.Task) (string, error) {
+	if err := r.reconcileEnvSecret(ctx, task); err != nil {
+		return "", err
+	}
+
+	imagePullSecret, err := r.reconcileRegistrySecret(ctx, task)
+
+	return imagePullSecret, errors.Wrap(err, "failed to reconcile registry secret")
+}
+
+// failTask marks a Task that cannot be started as failed, which also delivers
+// its completion callback
+func (r *TaskReconciler) failTask(ctx context.Context, logger lager.Logger, task *eiriniv1.Task, reason string) error {
+	logger.Info("failing-task", lager.Data{"reason": reason})
+
+	now := metav1.Now()
+	newStatus := task.Status.DeepCopy()
+	newStatus.ExecutionStatus = eiriniv1.TaskFailed
+	newStatus.FailureReason = reason
+	newStatus.EndTime = &now
+	newStatus.ObservedGeneration = task.Generation
+	setTaskExecutionConditions(newStatus, task.Generation)
+
+	return errors.Wrap(r.UpdateTaskStatus(ctx, task, *newStatus), "failed to update task status")
+}
+
+func (r *TaskReconciler) cancelTask(ctx context.Context, logger lager.Logger, task *eiriniv1.Task) error {
+	logger.Info("cancelling-task")
+
+	_, err := r.WorkloadClient.GetStatus(ctx, task.Spec.GUID)
+	if err == nil {
+		_, err = r.WorkloadClient.Delete(ctx, task.Spec.GUID)
+	}
+
+	if err != nil && !errors.Is(err, eirini.ErrNotFound) {
+		return errors.Wrap(err, "failed to delete task job")
+	}
+
+	now := metav1.Now()
+	newStatus := task.Status.DeepCopy()
+	newStatus.ExecutionStatus = eiriniv1.TaskCancelled
+	newStatus.EndTime = &now
+	newStatus.ObservedGeneration = task.Generation
+	setTaskExecutionConditions(newStatus, task.Generation)
+
+	return errors.Wrap(r.UpdateTaskStatus(ctx, task, *newStatus), "failed to update task status")
+}
+
+func (r *TaskReconciler) handleCompletedTask(ctx context.Context, logger lager.Logger, task *eiriniv1.Task) (ctrl.Result, error) {
+	if callbackPending(task) {
+		return r.deliverCompletionCallback(ctx, logger, task)
+	}
+
+	if err := r.removeCallbackFinalizer(ctx, task); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	expiresIn := time.Until(task.Status.EndTime.Add(r.ttl(task)))
+	if expiresIn > 0 {
+		return ctrl.Result{RequeueAfter: expiresIn}, nil
+	}
+
+	logger.Debug("deleting-expired-task")
+
+	err := r.Delete(ctx, task, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(client.IgnoreNotFound(err), "failed to delete expired task")
+	}
+
+	taskDeletions.Inc()
 
 	return ctrl.Result{}, nil
 }
 
+func (r *TaskReconciler) handleDeletedTask(ctx context.Context, logger lager.Logger, task *eiriniv1.Task) (ctrl.Result, error) {
+	if callbackPending(task) && controllerutil.ContainsFinalizer(task, TaskCompletionCallbackFinalizer) {
+		return r.deliverCompletionCallback(ctx, logger, task)
+	}
+
+	return ctrl.Result{}, r.removeCallbackFinalizer(ctx, task)
+}
+
+func (r *TaskReconciler) ttl(task *eiriniv1.Task) time.Duration {
+	if task.Spec.TTLSecondsAfterFinished != nil {
+		return time.Duration(*task.Spec.TTLSecondsAfterFinished) * time.Second
+	}
+
+	return time.Duration(r.TaskTTLSeconds) * time.Second
+}
+
+func (r *TaskReconciler) UpdateTaskStatus(ctx context.Context, task *eiriniv1.Task, newStatus eiriniv1.TaskStatus) error {
+	newTask := task.DeepCopy()
+	newTask.Status = newStatus
+
+	return r.Status().Patch(ctx, newTask, client.MergeFrom(task))
+}
+
+func (r *TaskReconciler) setOwnerFn(task *eiriniv1.Task) func(interface{}) error {
+	return func(resource interface{}) error {
+		obj, ok := resource.(metav1.Object)
+		if !ok {
+			return fmt.Errorf("failed to cast %v to metav1.Object", resource)
+		}
+
+		if err := ctrl.SetControllerReference(task, obj, r.Scheme); err != nil {
+			return errors.Wrap(err, "failed to set controller reference")
+		}
+
+		return nil
+	}
+}
+
+func taskHasCompleted(status eiriniv1.TaskStatus) bool {
+	return status.EndTime != nil &&
+		(status.ExecutionStatus == eiriniv1.TaskFailed ||
+			status.ExecutionStatus == eiriniv1.TaskSucceeded ||
+			status.ExecutionStatus == eiriniv1.TaskCancelled)
+}
+
+func applyJobStatus(status *eiriniv1.TaskStatus, jobStatus workloadsv1.TaskStatus) {
+	status.StartTime = jobStatus.StartTime
+	status.EndTime = jobStatus.EndTime
+	status.ExecutionStatus = eiriniv1.ExecutionStatus(jobStatus.ExecutionStatus)
+}
+
+func toAPITask(task *eiriniv1.Task) *api.Task {
+	apiTask := &api.Task{
+		GUID:               task.Spec.GUID,
+		Name:               task.Spec.Name,
+		Image:              task.Spec.Image,
+		CompletionCallback: task.Spec.CompletionCallback,
+		Env:                task.Spec.Env,
+		Command:            task.Spec.Command,
+		AppName:            task.Spec.AppName,
+		AppGUID:            task.Spec.AppGUID,
+		OrgName:            task.Spec.OrgName,
+		OrgGUID:            task.Spec.OrgGUID,
+		SpaceName:          task.Spec.SpaceName,
+		SpaceGUID:          task.Spec.SpaceGUID,
+		MemoryMB:           task.Spec.MemoryMB,
+		DiskMB:             task.Spec.DiskMB,
+		CPUWeight:          task.Spec.CPUWeight,
+	}
+
+	if task.Spec.EnvInSecret {
+		apiTask.Env = nil
+	}
+
+	if task.Spec.PrivateRegistry != nil && task.Spec.PrivateRegistry.SecretName == "" {
+		apiTask.PrivateRegistry = &api.PrivateRegistry{
+			Username: task.Spec.PrivateRegistry.Username,
+			Password: task.Spec.PrivateRegistry.Password,
+			Server:   util.ParseImageRegistryHost(task.Spec.Image),
+		}
+	}
+
+	return apiTask
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *TaskReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &eiriniv1.Task{}, taskGUIDIndex, indexTaskGUID)
+	if err != nil {
+		return errors.Wrap(err, "failed to index tasks by guid")
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&eiriniv1.Task{}).
+		Owns(&batchv1.Job{}).
+		Watches(
+			&source.Kind{Type: &corev1.Pod{}},
+			handler.EnqueueRequestsFromMapFunc(r.podToTask),
+			builder.WithPredicates(taskPodPredicate),
+		).
 		Complete(r)
 }
@@ -0,0 +1,384 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini-controller/controllers"
+	"code.cloudfoundry.org/eirini/k8s/jobs"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("TaskController", func() {
+	var (
+		ctx           context.Context
+		taskNamespace string
+		task          *eiriniv1.Task
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		ttl := int32(0)
+
+		taskNamespace = "test-ns-" + GenerateGUID()
+
+		err := k8sClient.Create(ctx, &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: taskNamespace,
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		task = &eiriniv1.Task{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: taskNamespace,
+				Name:      GenerateGUID(),
+			},
+			Spec: eiriniv1.TaskSpec{
+				GUID:                    GenerateGUID(),
+				Name:                    "my-task",
+				Image:                   "eirini/busybox",
+				Command:                 []string{"sh", "-c", "echo hi"},
+				AppName:                 "my-app",
+				TTLSecondsAfterFinished: &ttl,
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		Expect(k8sClient.Create(ctx, task)).To(Succeed())
+	})
+
+	It("creates a job owned by the task", func() {
+		Eventually(getJobItems(ctx, taskNamespace)).Should(HaveLen(1))
+
+		jobs, err := getJobItems(ctx, taskNamespace)()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(jobs[0].OwnerReferences).To(HaveLen(1))
+		Expect(jobs[0].OwnerReferences[0].Kind).To(Equal("Task"))
+		Expect(jobs[0].OwnerReferences[0].Name).To(Equal(task.Name))
+		Expect(*jobs[0].OwnerReferences[0].Controller).To(BeTrue())
+	})
+
+	When("the task sets a timeout and a retry limit", func() {
+		BeforeEach(func() {
+			timeout := int64(60)
+			maxRetries := int32(2)
+			task.Spec.TimeoutSeconds = &timeout
+			task.Spec.MaxRetries = &maxRetries
+		})
+
+		It("applies them to the job", func() {
+			Eventually(getJobItems(ctx, taskNamespace)).Should(HaveLen(1))
+
+			jobs, err := getJobItems(ctx, taskNamespace)()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*jobs[0].Spec.ActiveDeadlineSeconds).To(BeEquivalentTo(60))
+			Expect(*jobs[0].Spec.BackoffLimit).To(BeEquivalentTo(2))
+		})
+	})
+
+	When("the task keeps its env in a secret", func() {
+		BeforeEach(func() {
+			task.Spec.Env = map[string]string{"DB_PASSWORD": "hunter2"}
+			task.Spec.EnvInSecret = true
+		})
+
+		It("references the secret instead of putting the values in the job", func() {
+			Eventually(getJobItems(ctx, taskNamespace)).Should(HaveLen(1))
+
+			envSecret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: taskNamespace, Name: task.Name + "-env"}, envSecret)).To(Succeed())
+			Expect(envSecret.Data).To(HaveKeyWithValue("DB_PASSWORD", []byte("hunter2")))
+
+			jobs, err := getJobItems(ctx, taskNamespace)()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jobs[0].Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+				corev1.EnvVar{Name: "DB_PASSWORD", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: task.Name + "-env"},
+					Key:                  "DB_PASSWORD",
+				}}},
+			))
+			Expect(jobs[0].Spec.Template.Spec.Containers[0].Env).NotTo(ContainElement(corev1.EnvVar{Name: "DB_PASSWORD", Value: "hunter2"}))
+		})
+
+		When("a secret of the same name exists that the task does not control", func() {
+			BeforeEach(func() {
+				Expect(k8sClient.Create(ctx, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Namespace: taskNamespace, Name: task.Name + "-env"},
+					Data:       map[string][]byte{"DB_PASSWORD": []byte("someone-else")},
+				})).To(Succeed())
+			})
+
+			It("fails the task without creating a job", func() {
+				Eventually(func() (eiriniv1.ExecutionStatus, error) {
+					current := &eiriniv1.Task{}
+					err := k8sClient.Get(ctx, client.ObjectKeyFromObject(task), current)
+
+					return current.Status.ExecutionStatus, err
+				}).Should(Equal(eiriniv1.TaskFailed))
+
+				Consistently(getJobItems(ctx, taskNamespace), "2s").Should(BeEmpty())
+			})
+		})
+	})
+
+	When("the task takes its registry credentials from a dockerconfigjson secret", func() {
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: taskNamespace, Name: "pull-secret"},
+				Type:       corev1.SecretTypeDockerConfigJson,
+				Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)},
+			})).To(Succeed())
+
+			task.Spec.PrivateRegistry = &eiriniv1.PrivateRegistry{SecretName: "pull-secret"}
+		})
+
+		It("uses the secret as the pull secret", func() {
+			Eventually(getJobItems(ctx, taskNamespace)).Should(HaveLen(1))
+
+			jobs, err := getJobItems(ctx, taskNamespace)()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jobs[0].Spec.Template.Spec.ImagePullSecrets).To(ContainElement(corev1.LocalObjectReference{Name: "pull-secret"}))
+		})
+	})
+
+	When("the task container has terminated", func() {
+		var terminationMessage string
+
+		BeforeEach(func() {
+			terminationMessage = "out of memory"
+		})
+
+		JustBeforeEach(func() {
+			Eventually(getJobItems(ctx, taskNamespace)).Should(HaveLen(1))
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: taskNamespace,
+					Name:      "task-pod",
+					Labels: map[string]string{
+						jobs.LabelGUID:       task.Spec.GUID,
+						jobs.LabelSourceType: jobs.TaskSourceType,
+					},
+					Annotations: map[string]string{
+						jobs.AnnotationTaskContainerName: "opi-task",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "opi-task", Image: "eirini/busybox"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+				Name: "opi-task",
+				State: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{
+						ExitCode: 137,
+						Reason:   "OOMKilled",
+						Message:  terminationMessage,
+					},
+				},
+			}}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+		})
+
+		It("records the termination details in the task status", func() {
+			Eventually(func() (int32, error) {
+				current := &eiriniv1.Task{}
+				err := k8sClient.Get(ctx, client.ObjectKeyFromObject(task), current)
+				if err != nil || current.Status.ExitCode == nil {
+					return 0, err
+				}
+
+				return *current.Status.ExitCode, nil
+			}).Should(BeEquivalentTo(137))
+
+			current := &eiriniv1.Task{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(task), current)).To(Succeed())
+			Expect(current.Status.TerminationReason).To(Equal("OOMKilled"))
+			Expect(current.Status.TerminationMessage).To(Equal("out of memory"))
+		})
+
+		When("the termination message is too long", func() {
+			BeforeEach(func() {
+				terminationMessage = "a" + strings.Repeat("é", 1000)
+			})
+
+			It("truncates it on a rune boundary", func() {
+				Eventually(func() (string, error) {
+					current := &eiriniv1.Task{}
+					err := k8sClient.Get(ctx, client.ObjectKeyFromObject(task), current)
+
+					return current.Status.TerminationMessage, err
+				}).Should(Equal("a" + strings.Repeat("é", 511)))
+			})
+		})
+	})
+
+	When("the task has completed and its TTL has expired", func() {
+		JustBeforeEach(func() {
+			Eventually(getJobItems(ctx, taskNamespace)).Should(HaveLen(1))
+			completeTask(ctx, task, eiriniv1.TaskSucceeded)
+		})
+
+		It("deletes the task", func() {
+			Eventually(taskIsDeleted(ctx, task)).Should(BeTrue())
+		})
+	})
+
+	When("the task is cancelled", func() {
+		JustBeforeEach(func() {
+			Eventually(getJobItems(ctx, taskNamespace)).Should(HaveLen(1))
+
+			Eventually(func() error {
+				current := &eiriniv1.Task{}
+				if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(task), current); err != nil {
+					return err
+				}
+
+				current.Spec.Cancelled = true
+
+				return k8sClient.Update(ctx, current)
+			}).Should(Succeed())
+		})
+
+		It("deletes the job", func() {
+			Eventually(getJobItems(ctx, taskNamespace)).Should(BeEmpty())
+		})
+
+		When("the task TTL is long", func() {
+			BeforeEach(func() {
+				ttl := int32(3600)
+				task.Spec.TTLSecondsAfterFinished = &ttl
+			})
+
+			It("marks the task as cancelled", func() {
+				Eventually(func() (eiriniv1.ExecutionStatus, error) {
+					current := &eiriniv1.Task{}
+					err := k8sClient.Get(ctx, client.ObjectKeyFromObject(task), current)
+
+					return current.Status.ExecutionStatus, err
+				}).Should(Equal(eiriniv1.TaskCancelled))
+			})
+
+			It("sets the Failed condition with the cancelled reason", func() {
+				Eventually(func() (*metav1.Condition, error) {
+					current := &eiriniv1.Task{}
+					err := k8sClient.Get(ctx, client.ObjectKeyFromObject(task), current)
+
+					return meta.FindStatusCondition(current.Status.Conditions, eiriniv1.TaskConditionFailed), err
+				}).Should(And(
+					Not(BeNil()),
+					WithTransform(func(c *metav1.Condition) metav1.ConditionStatus { return c.Status }, Equal(metav1.ConditionTrue)),
+					WithTransform(func(c *metav1.Condition) string { return c.Reason }, Equal("TaskCancelled")),
+				))
+			})
+		})
+	})
+
+	When("the task has a completion callback", func() {
+		var (
+			server   *httptest.Server
+			requests chan controllers.TaskCompletedRequest
+		)
+
+		BeforeEach(func() {
+			requests = make(chan controllers.TaskCompletedRequest, 10)
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var request controllers.TaskCompletedRequest
+				Expect(json.NewDecoder(r.Body).Decode(&request)).To(Succeed())
+				requests <- request
+			}))
+
+			task.Spec.CompletionCallback = server.URL
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("adds the completion callback finalizer", func() {
+			Eventually(func() ([]string, error) {
+				current := &eiriniv1.Task{}
+				err := k8sClient.Get(ctx, client.ObjectKeyFromObject(task), current)
+
+				return current.Finalizers, err
+			}).Should(ContainElement(controllers.TaskCompletionCallbackFinalizer))
+		})
+
+		When("the task fails", func() {
+			JustBeforeEach(func() {
+				Eventually(getJobItems(ctx, taskNamespace)).Should(HaveLen(1))
+				completeTask(ctx, task, eiriniv1.TaskFailed)
+			})
+
+			It("delivers the callback exactly once and then deletes the task", func() {
+				Eventually(requests).Should(Receive(Equal(controllers.TaskCompletedRequest{
+					TaskGUID:      task.Spec.GUID,
+					Failed:        true,
+					FailureReason: "task failed",
+				})))
+				Eventually(taskIsDeleted(ctx, task)).Should(BeTrue())
+				Consistently(requests).ShouldNot(Receive())
+			})
+		})
+	})
+})
+
+var _ = Describe("CreateCompletionCallbackClient", func() {
+	It("only loads the CC certificates once a callback is delivered", func() {
+		callbackClient := controllers.CreateCompletionCallbackClient(false, "/does/not/exist")
+
+		err := callbackClient.Post(context.Background(), "https://cc.example.com", nil)
+		Expect(err).To(MatchError(ContainSubstring("failed to create cc tls client")))
+	})
+})
+
+func completeTask(ctx context.Context, task *eiriniv1.Task, executionStatus eiriniv1.ExecutionStatus) {
+	Eventually(func() error {
+		current := &eiriniv1.Task{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(task), current); err != nil {
+			return err
+		}
+
+		endTime := metav1.NewTime(time.Now().Add(-time.Minute))
+		current.Status = eiriniv1.TaskStatus{
+			StartTime:       &endTime,
+			EndTime:         &endTime,
+			ExecutionStatus: executionStatus,
+		}
+
+		return k8sClient.Status().Update(ctx, current)
+	}).Should(Succeed())
+}
+
+func taskIsDeleted(ctx context.Context, task *eiriniv1.Task) func() bool {
+	return func() bool {
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(task), &eiriniv1.Task{})
+
+		return apierrors.IsNotFound(err)
+	}
+}
+
+func getJobItems(ctx context.Context, namespace string) func() ([]batchv1.Job, error) {
+	return func() ([]batchv1.Job, error) {
+		jobs := batchv1.JobList{}
+		err := k8sClient.List(ctx, &jobs, client.InNamespace(namespace))
+
+		return jobs.Items, err
+	}
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/k8s/jobs"
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const reasonDeadlineExceeded = "DeadlineExceeded"
+
+func (r *TaskReconciler) setExecutionPolicyFn(task *eiriniv1.Task) func(interface{}) error {
+	return func(resource interface{}) error {
+		job, ok := resource.(*batchv1.Job)
+		if !ok {
+			return fmt.Errorf("failed to cast %v to batchv1.Job", resource)
+		}
+
+		if timeout := r.timeoutSeconds(task); timeout > 0 {
+			job.Spec.ActiveDeadlineSeconds = &timeout
+		}
+
+		maxRetries := r.maxRetries(task)
+		job.Spec.BackoffLimit = &maxRetries
+
+		return nil
+	}
+}
+
+func (r *TaskReconciler) timeoutSeconds(task *eiriniv1.Task) int64 {
+	if task.Spec.TimeoutSeconds != nil {
+		return *task.Spec.TimeoutSeconds
+	}
+
+	return r.DefaultTaskTimeoutSeconds
+}
+
+func (r *TaskReconciler) maxRetries(task *eiriniv1.Task) int32 {
+	if task.Spec.MaxRetries != nil {
+		return *task.Spec.MaxRetries
+	}
+
+	return r.DefaultTaskMaxRetries
+}
+
+func (r *TaskReconciler) getTaskJob(ctx context.Context, task *eiriniv1.Task) (*batchv1.Job, error) {
+	jobList := batchv1.JobList{}
+
+	err := r.List(ctx, &jobList, client.InNamespace(task.Namespace), client.MatchingLabels{
+		jobs.LabelGUID: task.Spec.GUID,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list task jobs")
+	}
+
+	if len(jobList.Items) == 0 {
+		return nil, nil
+	}
+
+	return &jobList.Items[0], nil
+}
+
+func applyJobConditions(status *eiriniv1.TaskStatus, job *batchv1.Job) {
+	if job == nil || status.ExecutionStatus != eiriniv1.TaskFailed {
+		return
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed &&
+			condition.Status == corev1.ConditionTrue &&
+			condition.Reason == reasonDeadlineExceeded {
+			status.FailureReason = fmt.Sprintf("task exceeded its timeout of %d seconds", *job.Spec.ActiveDeadlineSeconds)
+		}
+	}
+}
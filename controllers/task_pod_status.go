@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/k8s/jobs"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	taskGUIDIndex = "spec.GUID"
+
+	maxTerminationMessageLength = 1024
+
+	reasonOOMKilled = "OOMKilled"
+)
+
+var imagePullFailureReasons = map[string]bool{
+	"ErrImagePull":      true,
+	"ImagePullBackOff":  true,
+	"InvalidImageName":  true,
+	"ErrImageNeverPull": true,
+}
+
+var taskPodPredicate = predicate.NewPredicateFuncs(func(obj client.Object) bool {
+	return obj.GetLabels()[jobs.LabelSourceType] == jobs.TaskSourceType
+})
+
+func indexTaskGUID(obj client.Object) []string {
+	task, ok := obj.(*eiriniv1.Task)
+	if !ok {
+		return nil
+	}
+
+	return []string{task.Spec.GUID}
+}
+
+// podToTask maps a task pod to the Task it is running, so that changes in
+// the container state are reflected in the Task status.
+func (r *TaskReconciler) podToTask(obj client.Object) []reconcile.Request {
+	guid := obj.GetLabels()[jobs.LabelGUID]
+	if guid == "" {
+		return nil
+	}
+
+	tasks := eiriniv1.TaskList{}
+	if err := r.List(context.Background(), &tasks, client.InNamespace(obj.GetNamespace()), client.MatchingFields{taskGUIDIndex: guid}); err != nil {
+		r.Logger.Error("failed-to-list-tasks-for-pod", err)
+
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(tasks.Items))
+	for i := range tasks.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&tasks.Items[i])})
+	}
+
+	return requests
+}
+
+func (r *TaskReconciler) getTaskPod(ctx context.Context, task *eiriniv1.Task) (*corev1.Pod, error) {
+	pods := corev1.PodList{}
+
+	err := r.List(ctx, &pods, client.InNamespace(task.Namespace), client.MatchingLabels{
+		jobs.LabelGUID:       task.Spec.GUID,
+		jobs.LabelSourceType: jobs.TaskSourceType,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list task pods")
+	}
+
+	var latest *corev1.Pod
+
+	for i := range pods.Items {
+		if latest == nil || latest.CreationTimestamp.Before(&pods.Items[i].CreationTimestamp) {
+			latest = &pods.Items[i]
+		}
+	}
+
+	return latest, nil
+}
+
+func applyPodStatus(status *eiriniv1.TaskStatus, pod *corev1.Pod) {
+	containerStatus := getTaskContainerStatus(pod)
+
+	if containerStatus != nil && containerStatus.State.Terminated != nil {
+		terminated := containerStatus.State.Terminated
+		exitCode := terminated.ExitCode
+		status.ExitCode = &exitCode
+		status.TerminationReason = terminated.Reason
+		status.TerminationMessage = truncate(terminated.Message, maxTerminationMessageLength)
+	}
+
+	status.FailureReason = getFailureReason(status.ExecutionStatus, containerStatus)
+}
+
+func getTaskContainerStatus(pod *corev1.Pod) *corev1.ContainerStatus {
+	if pod == nil {
+		return nil
+	}
+
+	containerName := pod.Annotations[jobs.AnnotationTaskContainerName]
+
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == containerName {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+
+	return nil
+}
+
+func getFailureReason(executionStatus eiriniv1.ExecutionStatus, containerStatus *corev1.ContainerStatus) string {
+	if containerStatus != nil {
+		waiting := containerStatus.State.Waiting
+		if waiting != nil && imagePullFailureReasons[waiting.Reason] {
+			return fmt.Sprintf("failed to pull image: %s", waiting.Message)
+		}
+	}
+
+	if executionStatus != eiriniv1.TaskFailed {
+		return ""
+	}
+
+	if containerStatus == nil || containerStatus.State.Terminated == nil {
+		return taskFailedReason
+	}
+
+	terminated := containerStatus.State.Terminated
+	if terminated.Reason == reasonOOMKilled {
+		return "task was killed because it ran out of memory"
+	}
+
+	return fmt.Sprintf("task exited with code %d", terminated.ExitCode)
+}
+
+// truncate cuts s down to at most maxLength bytes, without splitting a
+// multi-byte rune
+func truncate(s string, maxLength int) string {
+	if len(s) <= maxLength {
+		return s
+	}
+
+	end := maxLength
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+
+	return s[:end]
+}
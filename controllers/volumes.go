@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"fmt"
+
+	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	"code.cloudfoundry.org/eirini/k8s/jobs"
+	"code.cloudfoundry.org/eirini/k8s/stset"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// setLRPVolumesFn replaces the volumes the vendored converter generates,
+// which only support claims, with ones built from the whole VolumeMount
+func setLRPVolumesFn(lrp *eiriniv1.LRP) func(interface{}) error {
+	return func(resource interface{}) error {
+		statefulSet, ok := resource.(*appsv1.StatefulSet)
+		if !ok {
+			return fmt.Errorf("failed to cast %v to appsv1.StatefulSet", resource)
+		}
+
+		volumes, volumeMounts := toVolumes(lrp.Spec.VolumeMounts)
+		statefulSet.Spec.Template.Spec.Volumes = volumes
+
+		containers := statefulSet.Spec.Template.Spec.Containers
+		for i := range containers {
+			if containers[i].Name == stset.ApplicationContainerName {
+				containers[i].VolumeMounts = volumeMounts
+			}
+		}
+
+		return nil
+	}
+}
+
+func setTaskVolumesFn(task *eiriniv1.Task) func(interface{}) error {
+	return func(resource interface{}) error {
+		job, ok := resource.(*batchv1.Job)
+		if !ok {
+			return fmt.Errorf("failed to cast %v to batchv1.Job", resource)
+		}
+
+		if len(task.Spec.VolumeMounts) == 0 {
+			return nil
+		}
+
+		volumes, volumeMounts := toVolumes(task.Spec.VolumeMounts)
+		podSpec := &job.Spec.Template.Spec
+		podSpec.Volumes = append(podSpec.Volumes, volumes...)
+
+		containerName := job.Spec.Template.Annotations[jobs.AnnotationTaskContainerName]
+		for i := range podSpec.Containers {
+			if podSpec.Containers[i].Name == containerName {
+				podSpec.Containers[i].VolumeMounts = append(podSpec.Containers[i].VolumeMounts, volumeMounts...)
+			}
+		}
+
+		return nil
+	}
+}
+
+// toVolumes names the volumes as eiriniv1.VolumeMount.VolumeName does. A
+// claim mounted more than once, such as with different subPaths, gets a
+// single volume that is only read-only when all its mounts are.
+func toVolumes(mounts []eiriniv1.VolumeMount) ([]corev1.Volume, []corev1.VolumeMount) {
+	volumes := []corev1.Volume{}
+	volumeMounts := []corev1.VolumeMount{}
+	claimVolumes := map[string]int{}
+
+	for i, mount := range mounts {
+		volume := corev1.Volume{Name: mount.VolumeName(i)}
+
+		switch {
+		case mount.ClaimName != "":
+			if v, ok := claimVolumes[mount.ClaimName]; ok {
+				claim := volumes[v].PersistentVolumeClaim
+				claim.ReadOnly = claim.ReadOnly && mount.ReadOnly
+				volumeMounts = append(volumeMounts, toVolumeMount(volume.Name, mount))
+
+				continue
+			}
+
+			claimVolumes[mount.ClaimName] = len(volumes)
+			volume.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: mount.ClaimName,
+				ReadOnly:  mount.ReadOnly,
+			}
+		case mount.ConfigMap != nil:
+			volume.ConfigMap = &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: mount.ConfigMap.Name},
+			}
+		case mount.Secret != nil:
+			volume.Secret = &corev1.SecretVolumeSource{SecretName: mount.Secret.SecretName}
+		case mount.EmptyDir != nil:
+			volume.EmptyDir = &corev1.EmptyDirVolumeSource{
+				Medium:    corev1.StorageMedium(mount.EmptyDir.Medium),
+				SizeLimit: mount.EmptyDir.SizeLimit,
+			}
+		default:
+			continue
+		}
+
+		volumes = append(volumes, volume)
+		volumeMounts = append(volumeMounts, toVolumeMount(volume.Name, mount))
+	}
+
+	return volumes, volumeMounts
+}
+
+func toVolumeMount(volumeName string, mount eiriniv1.VolumeMount) corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      volumeName,
+		MountPath: mount.MountPath,
+		ReadOnly:  mount.ReadOnly,
+		SubPath:   mount.SubPath,
+	}
+}
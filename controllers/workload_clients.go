@@ -1,9 +1,13 @@
 package controllers
 
 import (
+	"context"
+
 	"code.cloudfoundry.org/eirini"
+	"code.cloudfoundry.org/eirini/api"
 	"code.cloudfoundry.org/eirini/k8s"
 	"code.cloudfoundry.org/eirini/k8s/client"
+	"code.cloudfoundry.org/eirini/k8s/jobs"
 	"code.cloudfoundry.org/eirini/k8s/pdb"
 	"code.cloudfoundry.org/eirini/k8s/reconciler"
 	"code.cloudfoundry.org/eirini/k8s/stset"
@@ -16,6 +20,20 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
+// LRPWorkloadClient extends reconciler.LRPWorkloadCLient with the
+// operations of the vendored stset.Stopper, which the reconciler uses to
+// retire old versions and stop single instances
+type LRPWorkloadClient interface {
+	reconciler.LRPWorkloadCLient
+	Stop(ctx context.Context, identifier api.LRPIdentifier) error
+	StopInstance(ctx context.Context, identifier api.LRPIdentifier, index uint) error
+}
+
+type lrpWorkloadClient struct {
+	*prometheus.LRPClientDecorator
+	*stset.Stopper
+}
+
 func CreateLRPWorkloadsClient(
 	logger lager.Logger,
 	controllerClient ctrlruntimeclient.Client,
@@ -23,17 +41,9 @@ func CreateLRPWorkloadsClient(
 	cfg eirini.ControllerConfig,
 	scheme *runtime.Scheme,
 	latestMigration int,
-) (reconciler.LRPWorkloadCLient, error) {
+) (LRPWorkloadClient, error) {
 	logger = logger.Session("lrp-reconciler")
-	lrpToStatefulSetConverter := stset.NewLRPToStatefulSetConverter(
-		cfg.ApplicationServiceAccount,
-		cfg.RegistrySecretName,
-		cfg.UnsafeAllowAutomountServiceAccountToken,
-		cfg.AllowRunImageAsRoot,
-		latestMigration,
-		k8s.CreateLivenessProbe,
-		k8s.CreateReadinessProbe,
-	)
+	lrpToStatefulSetConverter := CreateLRPToStatefulSetConverter(cfg, latestMigration)
 	workloadClient := k8s.NewLRPClient(
 		logger.Session("stateful-set-desirer"),
 		client.NewSecret(clientset),
@@ -45,5 +55,47 @@ func CreateLRPWorkloadsClient(
 		stset.NewStatefulSetToLRPConverter(),
 	)
 
-	return prometheus.NewLRPClientDecorator(logger.Session("prometheus-decorator"), workloadClient, metrics.Registry, clock.RealClock{})
+	decorator, err := prometheus.NewLRPClientDecorator(logger.Session("prometheus-decorator"), workloadClient, metrics.Registry, clock.RealClock{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &lrpWorkloadClient{
+		LRPClientDecorator: decorator,
+		Stopper:            &workloadClient.Stopper,
+	}, nil
+}
+
+func CreateLRPToStatefulSetConverter(cfg eirini.ControllerConfig, latestMigration int) *stset.LRPToStatefulSet {
+	return stset.NewLRPToStatefulSetConverter(
+		cfg.ApplicationServiceAccount,
+		cfg.RegistrySecretName,
+		cfg.UnsafeAllowAutomountServiceAccountToken,
+		cfg.AllowRunImageAsRoot,
+		latestMigration,
+		k8s.CreateLivenessProbe,
+		k8s.CreateReadinessProbe,
+	)
+}
+
+func CreateTaskWorkloadsClient(
+	logger lager.Logger,
+	clientset kubernetes.Interface,
+	cfg eirini.ControllerConfig,
+	latestMigration int,
+) reconciler.TaskWorkloadClient {
+	logger = logger.Session("task-reconciler")
+	taskToJobConverter := jobs.NewTaskToJobConverter(
+		cfg.ApplicationServiceAccount,
+		cfg.RegistrySecretName,
+		cfg.UnsafeAllowAutomountServiceAccountToken,
+		latestMigration,
+	)
+
+	return k8s.NewTaskClient(
+		logger.Session("task-desirer"),
+		client.NewJob(clientset, cfg.WorkloadsNamespace),
+		client.NewSecret(clientset),
+		taskToJobConverter,
+	)
 }
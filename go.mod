@@ -12,12 +12,15 @@ require (
 	code.cloudfoundry.org/eirini v0.0.0-20210527142840-39e7adeb20ee
 	code.cloudfoundry.org/lager v2.0.0+incompatible
 	github.com/go-logr/logr v0.4.0
+	github.com/google/gofuzz v1.2.0
 	github.com/hashicorp/go-multierror v1.1.1
 	github.com/hashicorp/go-uuid v1.0.2
 	github.com/jinzhu/copier v0.3.2
 	github.com/onsi/ginkgo v1.16.3
 	github.com/onsi/gomega v1.13.0
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.10.0
+	golang.org/x/time v0.0.0-20210220033141-f8bda1e9f3ba
 	k8s.io/api v0.21.1
 	k8s.io/apimachinery v0.21.1
 	k8s.io/client-go v1.5.2
@@ -20,6 +20,7 @@ import (
 	"context"
 	"flag"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -34,9 +35,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	"code.cloudfoundry.org/eirini"
 	eiriniv1 "code.cloudfoundry.org/eirini-controller/api/v1"
+	eiriniv2 "code.cloudfoundry.org/eirini-controller/api/v2"
 	"code.cloudfoundry.org/eirini-controller/controllers"
 	"code.cloudfoundry.org/eirini/migrations"
 	//+kubebuilder:scaffold:imports
@@ -51,6 +54,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(eiriniv1.AddToScheme(scheme))
+	utilruntime.Must(eiriniv2.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -58,11 +62,57 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var taskTTLSeconds int
+	var ccTLSDisabled bool
+	var ccCertDir string
+	var completionCallbackRetryLimit int
+	var defaultTaskTimeoutSeconds int64
+	var defaultTaskMaxRetries int
+	var lrpReconcileQPS float64
+	var lrpReconcileBurst int
+	var versionReadyThreshold int
+	var versionTransitionTimeout time.Duration
+	var lrpDeletionGracePeriod time.Duration
+	var ingressClassName string
+	webhookDefaults := eiriniv1.BuiltinDefaults()
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.IntVar(&taskTTLSeconds, "task-ttl-seconds", 24*60*60,
+		"How long completed tasks are kept before they are deleted, unless overridden on the task.")
+	flag.BoolVar(&ccTLSDisabled, "cc-tls-disabled", false, "Send task completion callbacks to Cloud Controller without mTLS.")
+	flag.StringVar(&ccCertDir, "cc-certs-dir", eirini.CCCrtDir,
+		"The directory containing the client certificate, key and CA used to call Cloud Controller.")
+	flag.IntVar(&completionCallbackRetryLimit, "completion-callback-retry-limit", 10,
+		"How many times a failed task completion callback is retried.")
+	flag.Int64Var(&defaultTaskTimeoutSeconds, "default-task-timeout-seconds", 0,
+		"How long tasks that do not set a timeout may run. 0 means no timeout.")
+	flag.IntVar(&defaultTaskMaxRetries, "default-task-max-retries", 0,
+		"How many times failed tasks that do not set a retry limit are retried.")
+	flag.Float64Var(&lrpReconcileQPS, "lrp-reconcile-qps", 10,
+		"The overall rate at which LRP reconciles are queued.")
+	flag.IntVar(&lrpReconcileBurst, "lrp-reconcile-burst", 100,
+		"The number of LRP reconciles that can be queued in a burst above --lrp-reconcile-qps.")
+	flag.IntVar(&versionReadyThreshold, "version-ready-threshold", 100,
+		"The percentage of instances of a new LRP version that must be ready before the previous version is stopped.")
+	flag.DurationVar(&versionTransitionTimeout, "version-transition-timeout", 0,
+		"How long a new LRP version may take to become ready before it is rolled back. 0 means it is never rolled back.")
+	flag.DurationVar(&lrpDeletionGracePeriod, "lrp-deletion-grace-period", time.Minute,
+		"How long deleting an LRP waits for its pods to terminate before cleaning up its other resources.")
+	flag.StringVar(&ingressClassName, "ingress-class-name", "",
+		"The class of the Ingresses created for LRP routes. Uses the cluster default when empty.")
+	flag.StringVar(&webhookDefaults.HealthcheckType, "default-healthcheck-type", webhookDefaults.HealthcheckType,
+		"The health check type given to LRPs that expose ports and do not set one.")
+	flag.UintVar(&webhookDefaults.HealthcheckTimeoutMs, "default-healthcheck-timeout-ms", webhookDefaults.HealthcheckTimeoutMs,
+		"The start timeout given to LRPs that do not set one.")
+	flag.Int64Var(&webhookDefaults.DiskMB, "default-disk-mb", webhookDefaults.DiskMB,
+		"The disk quota given to LRPs and tasks that do not set one.")
+	flag.Int64Var(&webhookDefaults.CPUWeightMaxMemoryMB, "cpu-weight-max-memory-mb", webhookDefaults.CPUWeightMaxMemoryMB,
+		"The memory that gets the full CPU weight when LRPs and tasks do not set one. Smaller workloads get a proportional share.")
+	flag.StringVar(&webhookDefaults.ProcessType, "default-process-type", webhookDefaults.ProcessType,
+		"The process type given to LRPs that do not set one.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -89,13 +139,18 @@ func main() {
 	logger := controllers.NewLagrLogger(log.FromContext(context.Background()))
 	clientset := kubernetes.NewForConfigOrDie(kubeconfig)
 
+	controllerConfig := eirini.ControllerConfig{
+		CommonConfig: eirini.CommonConfig{
+			WorkloadsNamespace: "workloads",
+		},
+		TaskTTLSeconds: taskTTLSeconds,
+	}
+
 	lrpWorkloadsClient, err := controllers.CreateLRPWorkloadsClient(
 		logger,
 		mgr.GetClient(),
 		clientset,
-		eirini.ControllerConfig{CommonConfig: eirini.CommonConfig{
-			WorkloadsNamespace: "workloads",
-		}},
+		controllerConfig,
 		mgr.GetScheme(),
 		getLatestMigrationIndex(),
 	)
@@ -105,17 +160,40 @@ func main() {
 	}
 
 	if err = (&controllers.LRPReconciler{
-		Logger:         logger,
-		Client:         mgr.GetClient(),
-		Scheme:         mgr.GetScheme(),
-		WorkloadClient: lrpWorkloadsClient,
+		Logger:                   logger,
+		Client:                   mgr.GetClient(),
+		APIReader:                mgr.GetAPIReader(),
+		Scheme:                   mgr.GetScheme(),
+		WorkloadClient:           lrpWorkloadsClient,
+		StatefulSetConverter:     controllers.CreateLRPToStatefulSetConverter(controllerConfig, getLatestMigrationIndex()),
+		RateLimiter:              controllers.NewLRPRateLimiter(lrpReconcileQPS, lrpReconcileBurst),
+		VersionReadyThreshold:    versionReadyThreshold,
+		VersionTransitionTimeout: versionTransitionTimeout,
+		LRPDeletionGracePeriod:   lrpDeletionGracePeriod,
+		IngressClassName:         ingressClassName,
+		Recorder:                 mgr.GetEventRecorderFor("lrp-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "LRP")
 		os.Exit(1)
 	}
+	taskWorkloadsClient := controllers.CreateTaskWorkloadsClient(
+		logger,
+		clientset,
+		controllerConfig,
+		getLatestMigrationIndex(),
+	)
+
 	if err = (&controllers.TaskReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Logger:                       logger,
+		Client:                       mgr.GetClient(),
+		APIReader:                    mgr.GetAPIReader(),
+		Scheme:                       mgr.GetScheme(),
+		WorkloadClient:               taskWorkloadsClient,
+		TaskTTLSeconds:               controllerConfig.TaskTTLSeconds,
+		CallbackClient:               controllers.CreateCompletionCallbackClient(ccTLSDisabled, ccCertDir),
+		CompletionCallbackRetryLimit: completionCallbackRetryLimit,
+		DefaultTaskTimeoutSeconds:    defaultTaskTimeoutSeconds,
+		DefaultTaskMaxRetries:        int32(defaultTaskMaxRetries),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Task")
 		os.Exit(1)
@@ -124,6 +202,21 @@ func main() {
 		setupLog.Error(err, "unable to create webhook", "webhook", "LRP")
 		os.Exit(1)
 	}
+	if err = (&eiriniv1.Task{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "Task")
+		os.Exit(1)
+	}
+	if err = (&eiriniv2.LRP{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "LRP")
+		os.Exit(1)
+	}
+	if err = (&eiriniv2.Task{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "Task")
+		os.Exit(1)
+	}
+	mgr.GetWebhookServer().Register(eiriniv1.LRPDefaulterPath, &webhook.Admission{Handler: &eiriniv1.LRPDefaulter{Defaults: webhookDefaults}})
+	mgr.GetWebhookServer().Register(eiriniv1.TaskDefaulterPath, &webhook.Admission{Handler: &eiriniv1.TaskDefaulter{Defaults: webhookDefaults}})
+	mgr.GetWebhookServer().Register(controllers.InstanceIndexWebhookPath, &webhook.Admission{Handler: &controllers.InstanceIndexInjector{}})
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {